@@ -0,0 +1,91 @@
+package rollout
+
+import (
+	"strconv"
+
+	msgpack "github.com/vmihailenco/msgpack/v4"
+)
+
+// ActorKind names a kind of actor features are rolled out to
+type ActorKind string
+
+const (
+	// KindTeam is the kind of the teams features have always been rolled out to
+	KindTeam ActorKind = "team"
+
+	// KindUser is the kind of individual users
+	KindUser ActorKind = "user"
+
+	// KindAccount is the kind of accounts, e.g. organizations
+	KindAccount ActorKind = "account"
+)
+
+// Actor identifies something a feature is rolled out to, e.g. a team, user or account
+type Actor struct {
+	Kind ActorKind
+	ID   string
+}
+
+// NewActor constructs an Actor of the given kind with a string id
+func NewActor(kind ActorKind, id string) Actor {
+	return Actor{Kind: kind, ID: id}
+}
+
+// NewIntActor constructs an Actor of the given kind with an int64 id
+func NewIntActor(kind ActorKind, id int64) Actor {
+	return Actor{Kind: kind, ID: strconv.FormatInt(id, 10)}
+}
+
+// Team constructs the Actor of a team
+func Team(teamID int64) Actor {
+	return NewIntActor(KindTeam, teamID)
+}
+
+// String returns the actor as kind:id
+func (a Actor) String() string {
+	return string(a.Kind) + ":" + a.ID
+}
+
+// teamID returns the id of a team actor with an int64 id
+func (a Actor) teamID() (int64, bool) {
+	if a.Kind != KindTeam {
+		return 0, false
+	}
+
+	teamID, err := strconv.ParseInt(a.ID, 10, 64)
+	if err != nil || strconv.FormatInt(teamID, 10) != a.ID {
+		// not in the canonical form the id would be bucketed by
+		return 0, false
+	}
+
+	return teamID, true
+}
+
+type stringSet map[string]struct{}
+
+func (s stringSet) EncodeMsgpack(enc *msgpack.Encoder) error {
+	slice := make([]string, 0, len(s))
+	for str := range s {
+		slice = append(slice, str)
+	}
+	return enc.Encode(slice)
+}
+
+func (s *stringSet) DecodeMsgpack(dec *msgpack.Decoder) error {
+	n, err := dec.DecodeArrayLen()
+	if err != nil {
+		return err
+	}
+
+	set := make(stringSet, n)
+	for i := 0; i < n; i++ {
+		str, err := dec.DecodeString()
+		if err != nil {
+			return err
+		}
+		set[str] = struct{}{}
+	}
+	*s = set
+
+	return nil
+}
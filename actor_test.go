@@ -0,0 +1,40 @@
+package rollout
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vmihailenco/msgpack/v4"
+)
+
+func TestActor(t *testing.T) {
+	assert.Equal(t, Actor{Kind: KindUser, ID: "abc"}, NewActor(KindUser, "abc"))
+	assert.Equal(t, Actor{Kind: KindAccount, ID: "42"}, NewIntActor(KindAccount, 42))
+	assert.Equal(t, Actor{Kind: KindTeam, ID: "7"}, Team(7))
+	assert.Equal(t, "user:abc", NewActor(KindUser, "abc").String())
+
+	teamID, ok := Team(7).teamID()
+	assert.True(t, ok)
+	assert.EqualValues(t, 7, teamID)
+
+	_, ok = NewIntActor(KindUser, 7).teamID()
+	assert.False(t, ok)
+
+	// only the canonical form of a team id is the team
+	_, ok = NewActor(KindTeam, "007").teamID()
+	assert.False(t, ok)
+
+	_, ok = NewActor(KindTeam, "abc").teamID()
+	assert.False(t, ok)
+}
+
+func TestStringSet(t *testing.T) {
+	in := stringSet{"a": {}, "b": {}}
+
+	data, err := msgpack.Marshal(in)
+	assert.NoError(t, err)
+
+	var out stringSet
+	assert.NoError(t, msgpack.Unmarshal(data, &out))
+	assert.Equal(t, in, out)
+}
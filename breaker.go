@@ -0,0 +1,144 @@
+package rollout
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned instead of calling the store while the circuit breaker is open
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// breaker stops calling a failing store for a cooldown period once a threshold of consecutive failures is reached,
+// after which a single call is let through to probe whether the store has recovered
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu       sync.Mutex
+	failures int       // the number of consecutive failures
+	openedAt time.Time // when the breaker last opened
+	probing  bool      // whether a call is probing the store after the cooldown
+}
+
+// allow returns ErrCircuitOpen when the store shouldn't be called
+func (b *breaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < b.threshold {
+		return nil
+	}
+
+	if b.probing || b.now().Sub(b.openedAt) < b.cooldown {
+		return ErrCircuitOpen
+	}
+
+	b.probing = true
+
+	return nil
+}
+
+// record tracks the outcome of a store call
+func (b *breaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+
+	if err == nil {
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.failures >= b.threshold {
+		b.openedAt = b.now()
+	}
+}
+
+// release lets another call probe the store, without tracking the outcome of the call
+func (b *breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+}
+
+// breakerStore is a Store guarded by a circuit breaker
+type breakerStore struct {
+	store   Store
+	breaker *breaker
+}
+
+// Get implements Store
+func (s *breakerStore) Get(ctx context.Context, key string) ([]byte, error) {
+	if err := s.breaker.allow(); err != nil {
+		return nil, err
+	}
+
+	data, err := s.store.Get(ctx, key)
+	s.done(ctx, err)
+
+	return data, err
+}
+
+// MGet implements Store
+func (s *breakerStore) MGet(ctx context.Context, keys ...string) ([][]byte, error) {
+	if err := s.breaker.allow(); err != nil {
+		return nil, err
+	}
+
+	val, err := s.store.MGet(ctx, keys...)
+	s.done(ctx, err)
+
+	return val, err
+}
+
+// CompareAndSet implements Store
+func (s *breakerStore) CompareAndSet(ctx context.Context, key string, old, value []byte) (bool, error) {
+	if err := s.breaker.allow(); err != nil {
+		return false, err
+	}
+
+	swapped, err := s.store.CompareAndSet(ctx, key, old, value)
+	s.done(ctx, err)
+
+	return swapped, err
+}
+
+// Delete implements Store
+func (s *breakerStore) Delete(ctx context.Context, key string) (bool, error) {
+	if err := s.breaker.allow(); err != nil {
+		return false, err
+	}
+
+	deleted, err := s.store.Delete(ctx, key)
+	s.done(ctx, err)
+
+	return deleted, err
+}
+
+// List implements Store
+func (s *breakerStore) List(ctx context.Context, prefix string) ([]string, error) {
+	if err := s.breaker.allow(); err != nil {
+		return nil, err
+	}
+
+	keys, err := s.store.List(ctx, prefix)
+	s.done(ctx, err)
+
+	return keys, err
+}
+
+// done tracks the outcome of a store call, ignoring failures caused by the caller's context ending
+func (s *breakerStore) done(ctx context.Context, err error) {
+	if err != nil && ctx.Err() != nil {
+		s.breaker.release()
+		return
+	}
+
+	s.breaker.record(err)
+}
@@ -0,0 +1,94 @@
+package rollout
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBreaker(t *testing.T) {
+	now := time.Now()
+	b := &breaker{threshold: 2, cooldown: time.Minute, now: func() time.Time { return now }}
+
+	// closed until the threshold of consecutive failures
+	assert.NoError(t, b.allow())
+	b.record(errors.New("mock error"))
+	assert.NoError(t, b.allow())
+	b.record(nil)
+	assert.NoError(t, b.allow())
+	b.record(errors.New("mock error"))
+	assert.NoError(t, b.allow())
+	b.record(errors.New("mock error"))
+
+	// open during the cooldown
+	assert.Equal(t, ErrCircuitOpen, b.allow())
+
+	// a single probe after the cooldown
+	now = now.Add(time.Minute)
+	assert.NoError(t, b.allow())
+	assert.Equal(t, ErrCircuitOpen, b.allow())
+
+	// failed probe reopens
+	b.record(errors.New("mock error"))
+	assert.Equal(t, ErrCircuitOpen, b.allow())
+
+	// successful probe closes
+	now = now.Add(time.Minute)
+	assert.NoError(t, b.allow())
+	b.record(nil)
+	assert.NoError(t, b.allow())
+	assert.NoError(t, b.allow())
+}
+
+func TestManagerCircuitBreaker(t *testing.T) {
+	store := &failingStore{Store: NewMemoryStore(), fail: true}
+	manager := NewManagerWithStore(store, mockKeyPrefix, false, WithCircuitBreaker(3, time.Minute), WithFallback(FallbackClosed))
+
+	now := time.Now()
+	manager.now = func() time.Time { return now }
+
+	f := NewFeature("example")
+
+	// the store isn't called once the breaker opens
+	for i := 0; i < 10; i++ {
+		active, err := manager.IsActive(f)
+		assert.NoError(t, err)
+		assert.False(t, active)
+	}
+	assert.Equal(t, 3, store.calls)
+
+	// errors are returned without a fallback
+	manager.fallback = FallbackError
+	_, err := manager.IsActive(f)
+	assert.Equal(t, ErrCircuitOpen, err)
+	assert.Equal(t, ErrCircuitOpen, manager.Activate(f))
+
+	// recovered store is called again after the cooldown
+	store.fail = false
+	now = now.Add(time.Minute)
+	assert.NoError(t, manager.Activate(f))
+
+	active, err := manager.IsActive(f)
+	assert.NoError(t, err)
+	assert.True(t, active)
+}
+
+func TestCircuitBreakerContext(t *testing.T) {
+	store := &failingStore{Store: NewMemoryStore()}
+	manager := NewManagerWithStore(store, mockKeyPrefix, false, WithCircuitBreaker(1, time.Minute))
+
+	// failures caused by the caller's context don't open the breaker
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	store.fail = true
+	_, err := manager.IsActiveContext(ctx, NewFeature("example"))
+	assert.EqualError(t, err, "mock error")
+
+	store.fail = false
+	_, err = manager.IsActive(NewFeature("example"))
+	assert.NoError(t, err)
+}
@@ -0,0 +1,88 @@
+package rollout
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// cache keeps recently fetched features and segments in memory to avoid reading them from the store on every evaluation
+type cache struct {
+	ttl          time.Duration // how long a value is served without refreshing it
+	maxStaleness time.Duration // how long a value is served while being refreshed in the background
+
+	mu      sync.RWMutex
+	entries map[string]*cacheEntry
+}
+
+type cacheEntry struct {
+	value      interface{} // the fetched *Feature or *Segment, which must not be modified
+	fetchedAt  time.Time   // when the value was fetched from the store
+	refreshing int32       // whether the value is being refreshed in the background
+}
+
+func newCache(ttl, maxStaleness time.Duration) *cache {
+	if maxStaleness < ttl {
+		maxStaleness = ttl
+	}
+
+	return &cache{
+		ttl:          ttl,
+		maxStaleness: maxStaleness,
+		entries:      make(map[string]*cacheEntry),
+	}
+}
+
+// get returns the cached value when it can still be served, along with whether it should be refreshed
+func (c *cache) get(key string, now time.Time) (value interface{}, refresh bool, ok bool) {
+	c.mu.RLock()
+	entry, ok := c.entries[key]
+	c.mu.RUnlock()
+
+	if !ok {
+		return nil, false, false
+	}
+
+	age := now.Sub(entry.fetchedAt)
+	if age >= c.maxStaleness {
+		// too stale to be served
+		return nil, false, false
+	}
+	if age >= c.ttl {
+		// serve the stale value, while only one caller refreshes it
+		return entry.value, atomic.CompareAndSwapInt32(&entry.refreshing, 0, 1), true
+	}
+
+	return entry.value, false, true
+}
+
+// set caches the value unless a more recently fetched one is already cached
+func (c *cache) set(key string, value interface{}, fetchedAt time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if entry, ok := c.entries[key]; ok && entry.fetchedAt.After(fetchedAt) {
+		return
+	}
+
+	c.entries[key] = &cacheEntry{value: value, fetchedAt: fetchedAt}
+}
+
+// done marks the background refresh of the key as finished, allowing it to be refreshed again
+func (c *cache) done(key string) {
+	c.mu.RLock()
+	entry, ok := c.entries[key]
+	c.mu.RUnlock()
+
+	if ok {
+		atomic.StoreInt32(&entry.refreshing, 0)
+	}
+}
+
+// delete removes the key from the cache, so it's fetched from the store on the next evaluation
+func (c *cache) delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, key)
+}
@@ -0,0 +1,113 @@
+package rollout
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCache(t *testing.T) {
+	store := NewMemoryStore()
+	other := NewManagerWithStore(store, mockKeyPrefix, false)
+	manager := NewManagerWithStore(store, mockKeyPrefix, false, WithCache(time.Minute, 5*time.Minute))
+
+	now := time.Now()
+	manager.now = func() time.Time { return now }
+
+	f := NewFeature("example")
+
+	// feature not in the store is cached as inactive
+	active, err := manager.IsTeamActive(1, f)
+	assert.NoError(t, err)
+	assert.False(t, active)
+
+	// changes made elsewhere aren't seen within the ttl
+	assert.NoError(t, other.ActivateTeam(1, NewFeature("example")))
+
+	active, err = manager.IsTeamActive(1, f)
+	assert.NoError(t, err)
+	assert.False(t, active)
+
+	// changes made through the manager are seen immediately
+	assert.NoError(t, manager.ActivateTeam(2, NewFeature("example")))
+
+	results, err := manager.IsTeamActiveMulti(1, f)
+	assert.NoError(t, err)
+	assert.Equal(t, []bool{true}, results)
+
+	active, err = manager.IsTeamActive(2, f)
+	assert.NoError(t, err)
+	assert.True(t, active)
+
+	// stale features are served while refreshed in the background
+	assert.NoError(t, other.Activate(NewFeature("example")))
+	now = now.Add(2 * time.Minute)
+
+	active, err = manager.IsActive(f)
+	assert.NoError(t, err)
+	assert.False(t, active)
+
+	assert.Eventually(t, func() bool {
+		active, err := manager.IsActive(f)
+		return err == nil && active
+	}, time.Second, time.Millisecond)
+
+	// features beyond the max staleness are fetched from the store
+	assert.NoError(t, other.Deactivate(NewFeature("example")))
+	now = now.Add(10 * time.Minute)
+
+	active, err = manager.IsActive(f)
+	assert.NoError(t, err)
+	assert.False(t, active)
+
+	// deleted features are seen immediately
+	assert.NoError(t, manager.Activate(NewFeature("example")))
+	_, err = manager.Delete(NewFeature("example"))
+	assert.NoError(t, err)
+
+	active, err = manager.IsActive(f)
+	assert.NoError(t, err)
+	assert.False(t, active)
+}
+
+func TestCacheSet(t *testing.T) {
+	c := newCache(time.Minute, 0)
+	assert.Equal(t, time.Minute, c.maxStaleness)
+
+	now := time.Now()
+	older := NewFeature("older")
+	newer := NewFeature("newer")
+
+	// a more recently fetched feature isn't replaced by an older one
+	c.set("example", newer, now)
+	c.set("example", older, now.Add(-time.Second))
+
+	f, refresh, ok := c.get("example", now)
+	assert.True(t, ok)
+	assert.False(t, refresh)
+	assert.Equal(t, newer, f)
+
+	// only a single caller refreshes a stale feature
+	_, refresh, ok = c.get("example", now.Add(time.Minute-time.Nanosecond))
+	assert.True(t, ok)
+	assert.False(t, refresh)
+
+	c.maxStaleness = 2 * time.Minute
+	_, refresh, ok = c.get("example", now.Add(time.Minute))
+	assert.True(t, ok)
+	assert.True(t, refresh)
+
+	_, refresh, ok = c.get("example", now.Add(time.Minute))
+	assert.True(t, ok)
+	assert.False(t, refresh)
+
+	c.done("example")
+	_, refresh, ok = c.get("example", now.Add(time.Minute))
+	assert.True(t, ok)
+	assert.True(t, refresh)
+
+	// too stale to be served
+	_, _, ok = c.get("example", now.Add(2*time.Minute))
+	assert.False(t, ok)
+}
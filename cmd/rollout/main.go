@@ -1,19 +1,20 @@
 package main
 
 import (
-	"bytes"
+	"errors"
 	"fmt"
 	"log"
+	"math"
 	"os"
 	"sort"
 	"strconv"
 	"strings"
 	"text/tabwriter"
+	"time"
 
 	"github.com/go-redis/redis/v7"
 	rollout "github.com/salesloft/gorollout"
 	"github.com/urfave/cli/v2"
-	"github.com/vmihailenco/msgpack/v4"
 )
 
 var (
@@ -32,6 +33,27 @@ var (
 				Usage: "Key prefix for feature flags",
 				Value: "rollout",
 			},
+			&cli.StringFlag{
+				Name:    "author",
+				Usage:   "Who the changes are recorded as made by",
+				EnvVars: []string{"ROLLOUT_AUTHOR", "USER"},
+			},
+			&cli.StringFlag{
+				Name:  "reason",
+				Usage: "Why the changes are made, recorded in the history of the feature flags",
+			},
+			&cli.IntFlag{
+				Name:    "history-limit",
+				Usage:   "The number of changes kept in the history of each feature flag, 0 to not record the history, which needs Redis 5 or later and is otherwise skipped with a warning",
+				Value:   rollout.DefaultHistoryLimit,
+				EnvVars: []string{"ROLLOUT_HISTORY_LIMIT"},
+			},
+		},
+
+		Before: func(c *cli.Context) error {
+			c.Context = rollout.ContextWithAuthor(c.Context, c.String("author"))
+			c.Context = rollout.ContextWithReason(c.Context, c.String("reason"))
+			return nil
 		},
 
 		Commands: []*cli.Command{
@@ -39,10 +61,68 @@ var (
 				Name:   "list",
 				Usage:  "List all active feature flags",
 				Action: listFeatureFlags,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "owner",
+						Usage: "Only list the feature flags owned by the team",
+					},
+					&cli.StringSliceFlag{
+						Name:  "tag",
+						Usage: "Only list the feature flags tagged with the tag, repeatable",
+					},
+				},
+			},
+			{
+				Name:      "metadata",
+				Usage:     "Show the description, owner, tags and timestamps of a feature flag",
+				Action:    showMetadataFeatureFlag,
+				ArgsUsage: "[feature name]",
+			},
+			{
+				Name:      "set-metadata",
+				Usage:     "Edit the description, owner and tags of a feature flag",
+				Action:    setMetadataFeatureFlag,
+				ArgsUsage: "[feature name]",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "description",
+						Usage: "Why the feature flag exists",
+					},
+					&cli.StringFlag{
+						Name:  "owner",
+						Usage: "The team owning the feature flag",
+					},
+					&cli.StringSliceFlag{
+						Name:  "add-tag",
+						Usage: "Tag the feature flag, repeatable",
+					},
+					&cli.StringSliceFlag{
+						Name:  "remove-tag",
+						Usage: "Remove a tag from the feature flag, repeatable",
+					},
+				},
+			},
+			{
+				Name:      "history",
+				Usage:     "List the most recent changes made to a feature flag, and by whom",
+				Action:    showHistoryFeatureFlag,
+				ArgsUsage: "[feature name]",
+				Flags: []cli.Flag{
+					&cli.Int64Flag{
+						Name:  "version",
+						Usage: "Show the state of the feature flag before and after the change making the version",
+					},
+				},
+			},
+			{
+				Name:      "rollback",
+				Usage:     "Restore a feature flag to the state a version in its history left it in",
+				Action:    rollbackFeatureFlag,
+				ArgsUsage: "[feature name] [version]",
 			},
 			{
 				Name:      "activate-percentage",
-				Usage:     "Rollout a feature flag the given percentage",
+				Usage:     "Rollout a feature flag the given percentage, with up to two decimals",
 				Action:    activatePercentageFeatureFlag,
 				ArgsUsage: "[feature name] [percentage]",
 			},
@@ -70,92 +150,418 @@ var (
 				Action:    deactivateTeamFeatureFlag,
 				ArgsUsage: "[feature name] [team_id]",
 			},
+			{
+				Name:      "block-team",
+				Usage:     "Prevent a feature flag from being active for a specific team",
+				Action:    blockTeamFeatureFlag,
+				ArgsUsage: "[feature name] [team_id]",
+			},
+			{
+				Name:      "unblock-team",
+				Usage:     "Allow a feature flag to be active for a specific blocked team again",
+				Action:    unblockTeamFeatureFlag,
+				ArgsUsage: "[feature name] [team_id]",
+			},
+			{
+				Name:      "rules",
+				Usage:     "List the targeting rules of a feature flag",
+				Action:    listRulesFeatureFlag,
+				ArgsUsage: "[feature name]",
+			},
+			{
+				Name:      "add-rule",
+				Usage:     "Activate a feature flag for attributes matching all the clauses, e.g. 'plan in pro,enterprise' 'seats gte 50'",
+				Action:    addRuleFeatureFlag,
+				ArgsUsage: "[feature name] [clause...]",
+			},
+			{
+				Name:      "remove-rule",
+				Usage:     "Remove a targeting rule of a feature flag by its index",
+				Action:    removeRuleFeatureFlag,
+				ArgsUsage: "[feature name] [index]",
+			},
+			{
+				Name:      "set-variant",
+				Usage:     "Set the weight of a variant of a feature flag, adding the variant when missing",
+				Action:    setVariantFeatureFlag,
+				ArgsUsage: "[feature name] [variant] [weight]",
+			},
+			{
+				Name:      "remove-variant",
+				Usage:     "Remove a variant of a feature flag",
+				Action:    removeVariantFeatureFlag,
+				ArgsUsage: "[feature name] [variant]",
+			},
+			{
+				Name:      "payloads",
+				Usage:     "List the payloads served along with the variants of a feature flag",
+				Action:    listPayloadsFeatureFlag,
+				ArgsUsage: "[feature name]",
+			},
+			{
+				Name:      "set-payload",
+				Usage:     "Set the payload served along with a feature flag or one of its variants (string, number or json)",
+				Action:    setPayloadFeatureFlag,
+				ArgsUsage: "[feature name] [type] [value]",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "variant",
+						Usage: "Variant the payload is served along with, none for the feature flag",
+					},
+				},
+			},
+			{
+				Name:      "remove-payload",
+				Usage:     "Remove the payload served along with a feature flag or one of its variants",
+				Action:    removePayloadFeatureFlag,
+				ArgsUsage: "[feature name]",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "variant",
+						Usage: "Variant the payload is served along with, none for the feature flag",
+					},
+				},
+			},
+			{
+				Name:   "scheduled",
+				Usage:  "List the upcoming scheduled changes of all feature flags",
+				Action: listScheduledChanges,
+			},
+			{
+				Name:      "schedule-activate",
+				Usage:     "Activate a feature flag for all teams at the given RFC 3339 time, e.g. 2021-06-01T00:00:00Z",
+				Action:    scheduleActivateFeatureFlag,
+				ArgsUsage: "[feature name] [time]",
+			},
+			{
+				Name:      "schedule-deactivate",
+				Usage:     "Deactivate a feature flag for all teams at the given RFC 3339 time",
+				Action:    scheduleDeactivateFeatureFlag,
+				ArgsUsage: "[feature name] [time]",
+			},
+			{
+				Name:      "schedule-percentage",
+				Usage:     "Rollout a feature flag the given percentage, with up to two decimals, at the given RFC 3339 time",
+				Action:    schedulePercentageFeatureFlag,
+				ArgsUsage: "[feature name] [time] [percentage]",
+			},
+			{
+				Name:      "unschedule",
+				Usage:     "Cancel a scheduled change of a feature flag by its index",
+				Action:    unscheduleFeatureFlag,
+				ArgsUsage: "[feature name] [index]",
+			},
+			{
+				Name:      "ramp",
+				Usage:     "Show the progress of the ramp of a feature flag",
+				Action:    showRampFeatureFlag,
+				ArgsUsage: "[feature name]",
+			},
+			{
+				Name:      "start-ramp",
+				Usage:     "Progressively rollout a feature flag through steps of a percentage and a duration, e.g. 1:24h 5:24h 25:48h 100",
+				Action:    startRampFeatureFlag,
+				ArgsUsage: "[feature name] [percentage:duration...]",
+			},
+			{
+				Name:      "pause-ramp",
+				Usage:     "Hold a feature flag at the percentage of the current step of its ramp",
+				Action:    pauseRampFeatureFlag,
+				ArgsUsage: "[feature name]",
+			},
+			{
+				Name:      "resume-ramp",
+				Usage:     "Resume the paused ramp of a feature flag",
+				Action:    resumeRampFeatureFlag,
+				ArgsUsage: "[feature name]",
+			},
+			{
+				Name:      "abort-ramp",
+				Usage:     "Stop the ramp of a feature flag, rolling it back to 0 percent",
+				Action:    abortRampFeatureFlag,
+				ArgsUsage: "[feature name]",
+			},
+			{
+				Name:      "dependencies",
+				Usage:     "Show the prerequisites of a feature flag, or of all feature flags, as a tree",
+				Action:    showDependencies,
+				ArgsUsage: "[feature name]",
+			},
+			{
+				Name:      "add-prerequisite",
+				Usage:     "Only activate a feature flag for the teams a prerequisite feature flag is active for",
+				Action:    addPrerequisiteFeatureFlag,
+				ArgsUsage: "[feature name] [prerequisite name]",
+			},
+			{
+				Name:      "remove-prerequisite",
+				Usage:     "Remove a prerequisite of a feature flag",
+				Action:    removePrerequisiteFeatureFlag,
+				ArgsUsage: "[feature name] [prerequisite name]",
+			},
+			{
+				Name:      "set-salt",
+				Usage:     "Set the salt teams are bucketed with, empty to bucket by the feature flag name",
+				Action:    setSaltFeatureFlag,
+				ArgsUsage: "[feature name] [salt]",
+			},
+			{
+				Name:      "set-hash",
+				Usage:     "Set the algorithm teams are bucketed with (crc32, murmur3 or xxhash)",
+				Action:    setHashFeatureFlag,
+				ArgsUsage: "[feature name] [hash]",
+			},
+			{
+				Name:      "activate-actor",
+				Usage:     "Activate a feature flag for a specific actor",
+				Action:    activateActorFeatureFlag,
+				ArgsUsage: "[feature name] [kind] [id]",
+			},
+			{
+				Name:      "deactivate-actor",
+				Usage:     "Deactivate a feature flag for a specific actor",
+				Action:    deactivateActorFeatureFlag,
+				ArgsUsage: "[feature name] [kind] [id]",
+			},
+			{
+				Name:      "activate-actor-percentage",
+				Usage:     "Rollout a feature flag the given percentage of actors of a kind",
+				Action:    activateActorPercentageFeatureFlag,
+				ArgsUsage: "[feature name] [kind] [percentage]",
+			},
+			{
+				Name:      "activate-segment",
+				Usage:     "Activate a feature flag for the teams of a segment",
+				Action:    activateSegmentFeatureFlag,
+				ArgsUsage: "[feature name] [segment name]",
+			},
+			{
+				Name:      "deactivate-segment",
+				Usage:     "Deactivate a feature flag for the teams of a segment",
+				Action:    deactivateSegmentFeatureFlag,
+				ArgsUsage: "[feature name] [segment name]",
+			},
+			{
+				Name:   "list-segments",
+				Usage:  "List all segments of teams",
+				Action: listSegments,
+			},
+			{
+				Name:      "add-segment-teams",
+				Usage:     "Add teams to a segment, creating it when it doesn't exist",
+				Action:    addSegmentTeams,
+				ArgsUsage: "[segment name] [team_id...]",
+			},
+			{
+				Name:      "remove-segment-teams",
+				Usage:     "Remove teams from a segment",
+				Action:    removeSegmentTeams,
+				ArgsUsage: "[segment name] [team_id...]",
+			},
+			{
+				Name:      "delete-segment",
+				Usage:     "Delete a segment from the database",
+				Action:    deleteSegment,
+				ArgsUsage: "[segment name]",
+			},
+			{
+				Name:      "segment-history",
+				Usage:     "List the most recent changes made to a segment, and by whom",
+				Action:    showSegmentHistory,
+				ArgsUsage: "[segment name]",
+			},
 			{
 				Name:      "delete",
 				Usage:     "Delete a feature flag from the database",
 				Action:    deleteFeatureFlag,
 				ArgsUsage: "[feature name]",
 			},
+			{
+				Name:   "migrate",
+				Usage:  "Rewrite the feature flags stored as tuples as versioned maps, which older readers can't read",
+				Action: migrateFeatureFlags,
+			},
 		},
 	}
 )
 
 func main() {
 	if err := app.Run(os.Args); err != nil {
+		if changed(err) {
+			log.Printf("warning: %v", err)
+			return
+		}
+
 		log.Fatal(err)
 	}
 }
 
-func listFeatureFlags(c *cli.Context) error {
-	client := redis.NewUniversalClient(
+// changed reports whether the error was returned by a change which was made anyway, e.g. by servers
+// older than Redis 5 unable to record the history
+func changed(err error) bool {
+	var historyErr *rollout.HistoryError
+	var notifyErr *rollout.NotifyError
+
+	return errors.As(err, &historyErr) || errors.As(err, &notifyErr)
+}
+
+// newTable constructs a writer aligning the tab separated cells of a table, padded so that cells as wide as a tab
+// don't run into the next column
+func newTable() *tabwriter.Writer {
+	w := new(tabwriter.Writer)
+	w.Init(os.Stdout, 8, 8, 1, '\t', 0)
+
+	return w
+}
+
+// newClient constructs a redis client for the configured hosts
+func newClient(c *cli.Context) redis.UniversalClient {
+	return redis.NewUniversalClient(
 		&redis.UniversalOptions{
 			Addrs: strings.Split(c.String("host"), ","),
 		},
 	)
+}
+
+// newManager constructs a feature manager for the configured hosts and prefix
+func newManager(c *cli.Context) *rollout.Manager {
+	return rollout.NewManager(newClient(c), c.String("prefix"), false, rollout.WithHistory(c.Int("history-limit")))
+}
 
-	var cursor uint64
-	var allKeys []string
+func listFeatureFlags(c *cli.Context) error {
+	features, err := newManager(c).ListContext(c.Context)
+	if err != nil {
+		return err
+	}
 
-	for {
-		var keys []string
-		var err error
-		keys, cursor, err = client.Scan(cursor, c.String("prefix")+":*", 100).Result()
-		if err != nil {
-			return err
+	w := newTable()
+	defer w.Flush()
+
+	fmt.Fprintf(w, " %s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t", "flag", "percentage", "default", "active_teams", "active_segments", "active_actors", "blocked_teams", "bucketing", "variants", "owner", "tags")
+	fmt.Fprintf(w, "\n %s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t", "----", "----------", "-------", "------------", "---------------", "-------------", "-------------", "---------", "--------", "-----", "----")
+
+	for _, feature := range features {
+		metadata := feature.Metadata()
+		if !matchesMetadata(c, metadata) {
+			continue
+		}
+
+		teamIDs := make([]string, 0)
+		for _, teamID := range feature.TeamIDs() {
+			teamIDs = append(teamIDs, strconv.FormatInt(teamID, 10))
+		}
+
+		blockedTeamIDs := make([]string, 0)
+		for _, teamID := range feature.BlockedTeamIDs() {
+			blockedTeamIDs = append(blockedTeamIDs, strconv.FormatInt(teamID, 10))
+		}
+
+		actors := make([]string, 0)
+		for _, kind := range feature.ActorKinds() {
+			actors = append(actors, fmt.Sprintf("%s=%d%%", kind, feature.ActorPercentage(kind)))
+		}
+		for _, actor := range feature.Actors() {
+			actors = append(actors, actor.String())
 		}
 
-		allKeys = append(allKeys, keys...)
+		bucketing := string(feature.Hash())
+		if salt := feature.Salt(); salt != "" {
+			bucketing += " salt=" + salt
+		}
 
-		if cursor == 0 {
-			break
+		variants := make([]string, 0)
+		for _, variant := range feature.Variants() {
+			variants = append(variants, fmt.Sprintf("%s=%d", variant.Name, variant.Weight))
 		}
+
+		fmt.Fprintf(w, "\n %s\t%s\t%t\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t", feature.Name(), formatBasisPoints(feature.BasisPoints()), feature.Default(), strings.Join(teamIDs, ","), strings.Join(feature.Segments(), ","), strings.Join(actors, ","), strings.Join(blockedTeamIDs, ","), bucketing, strings.Join(variants, ","), metadata.Owner, strings.Join(metadata.Tags, ","))
 	}
 
-	sort.Strings(allKeys)
+	fmt.Fprint(w, "\n")
 
-	var results []interface{}
-	var err error
-	if len(allKeys) > 0 {
-		results, err = client.MGet(allKeys...).Result()
-		if err != nil {
-			return err
+	return nil
+}
+
+// matchesMetadata returns whether the metadata matches the owner and tags filters of the command
+func matchesMetadata(c *cli.Context, metadata rollout.Metadata) bool {
+	if c.IsSet("owner") && metadata.Owner != c.String("owner") {
+		return false
+	}
+
+	for _, tag := range c.StringSlice("tag") {
+		if !metadata.HasTag(tag) {
+			return false
 		}
 	}
 
-	w := new(tabwriter.Writer)
-	w.Init(os.Stdout, 8, 8, 0, '\t', 0)
+	return true
+}
+
+func showMetadataFeatureFlag(c *cli.Context) error {
+	name := c.Args().Get(0)
+	if name == "" {
+		return cli.NewExitError("Missing required feature flag name", 1)
+	}
+
+	ff, err := findFeatureFlag(c, name)
+	if err != nil {
+		return err
+	}
+
+	metadata := ff.Metadata()
+
+	w := newTable()
 	defer w.Flush()
 
-	fmt.Fprintf(w, " %s\t%s\t%s\t", "flag", "percentage", "active_teams")
-	fmt.Fprintf(w, "\n %s\t%s\t%s\t", "----", "----------", "------------")
+	fmt.Fprintf(w, " %s\t%s\t\n", "description", metadata.Description)
+	fmt.Fprintf(w, " %s\t%s\t\n", "owner", metadata.Owner)
+	fmt.Fprintf(w, " %s\t%s\t\n", "tags", strings.Join(metadata.Tags, ","))
+	fmt.Fprintf(w, " %s\t%s\t\n", "created_at", formatTime(metadata.CreatedAt))
+	fmt.Fprintf(w, " %s\t%s\t\n", "updated_at", formatTime(metadata.UpdatedAt))
+	fmt.Fprintf(w, " %s\t%s\t\n", "updated_by", metadata.UpdatedBy)
 
-	for i, result := range results {
-		dec := msgpack.NewDecoder(bytes.NewBufferString(result.(string)))
+	return nil
+}
 
-		percentage, err := dec.DecodeUint8()
-		if err != nil {
-			return err
-		}
+// formatTime formats the time as RFC 3339, empty for the zero time
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
 
-		var teamIDs []string
-		n, err := dec.DecodeArrayLen()
-		if err != nil {
-			return err
-		}
+	return t.Format(time.RFC3339)
+}
 
-		if n > 0 {
-			teamIDs = make([]string, n)
-			for i := 0; i < n; i++ {
-				teamID, err := dec.DecodeInt64()
-				if err != nil {
-					return err
-				}
+func showHistoryFeatureFlag(c *cli.Context) error {
+	ff := rollout.NewFeature(c.Args().Get(0))
+	if ff.Name() == "" {
+		return cli.NewExitError("Missing required feature flag name", 1)
+	}
+
+	history, err := newManager(c).HistoryContext(c.Context, ff)
+	if err != nil {
+		return err
+	}
 
-				teamIDs[i] = strconv.FormatInt(teamID, 10)
+	if c.IsSet("version") {
+		for _, entry := range history {
+			if entry.Version == c.Int64("version") {
+				printHistoryEntry(entry)
+				return nil
 			}
 		}
 
-		fmt.Fprintf(w, "\n %s\t%d\t%s\t", allKeys[i][8:], percentage, strings.Join(teamIDs, ","))
+		return cli.NewExitError("Version was not found in the history", 1)
+	}
+
+	w := newTable()
+	defer w.Flush()
+
+	fmt.Fprintf(w, " %s\t%s\t%s\t%s\t%s\t", "version", "at", "author", "operation", "reason")
+	fmt.Fprintf(w, "\n %s\t%s\t%s\t%s\t%s\t", "-------", "--", "------", "---------", "------")
+
+	for _, entry := range history {
+		fmt.Fprintf(w, "\n %d\t%s\t%s\t%s\t%s\t", entry.Version, formatTime(entry.At), entry.Author, entry.Operation, entry.Reason)
 	}
 
 	fmt.Fprint(w, "\n")
@@ -163,6 +569,114 @@ func listFeatureFlags(c *cli.Context) error {
 	return nil
 }
 
+func rollbackFeatureFlag(c *cli.Context) error {
+	ff := rollout.NewFeature(c.Args().Get(0))
+	if ff.Name() == "" {
+		return cli.NewExitError("Missing required feature flag name", 1)
+	}
+
+	versionStr := c.Args().Get(1)
+	if versionStr == "" {
+		return cli.NewExitError("Missing required version", 1)
+	}
+
+	version, err := strconv.ParseInt(versionStr, 10, 64)
+	if err != nil {
+		return err
+	}
+
+	return newManager(c).RollbackContext(c.Context, ff, version)
+}
+
+// printHistoryEntry prints the change along with the state of the feature before and after it
+func printHistoryEntry(entry rollout.HistoryEntry) {
+	w := newTable()
+	defer w.Flush()
+
+	fmt.Fprintf(w, " %s\t%d\t\n", "version", entry.Version)
+	fmt.Fprintf(w, " %s\t%s\t\n", "at", formatTime(entry.At))
+	fmt.Fprintf(w, " %s\t%s\t\n", "author", entry.Author)
+	fmt.Fprintf(w, " %s\t%s\t\n", "operation", entry.Operation)
+	fmt.Fprintf(w, " %s\t%s\t\n", "reason", entry.Reason)
+	fmt.Fprintf(w, " %s\t%s\t\n", "before", formatState(entry.Before))
+	fmt.Fprintf(w, " %s\t%s\t\n", "after", formatState(entry.After))
+}
+
+// formatState summarizes who the feature is rolled out to, e.g. "percentage=25 teams=1,2", "(none)" when it isn't persisted
+func formatState(feature *rollout.Feature) string {
+	if feature == nil {
+		return "(none)"
+	}
+
+	teamIDs := make([]string, 0)
+	for _, teamID := range feature.TeamIDs() {
+		teamIDs = append(teamIDs, strconv.FormatInt(teamID, 10))
+	}
+
+	blockedTeamIDs := make([]string, 0)
+	for _, teamID := range feature.BlockedTeamIDs() {
+		blockedTeamIDs = append(blockedTeamIDs, strconv.FormatInt(teamID, 10))
+	}
+
+	actors := make([]string, 0)
+	for _, kind := range feature.ActorKinds() {
+		actors = append(actors, fmt.Sprintf("%s=%d%%", kind, feature.ActorPercentage(kind)))
+	}
+	for _, actor := range feature.Actors() {
+		actors = append(actors, actor.String())
+	}
+
+	variants := make([]string, 0)
+	for _, variant := range feature.Variants() {
+		variants = append(variants, fmt.Sprintf("%s=%d", variant.Name, variant.Weight))
+	}
+
+	state := []string{"percentage=" + formatBasisPoints(feature.BasisPoints())}
+	for _, field := range []struct {
+		name   string
+		values []string
+	}{
+		{"teams", teamIDs},
+		{"segments", feature.Segments()},
+		{"actors", actors},
+		{"blocked_teams", blockedTeamIDs},
+		{"variants", variants},
+		{"prerequisites", feature.Prerequisites()},
+	} {
+		if len(field.values) > 0 {
+			state = append(state, field.name+"="+strings.Join(field.values, ","))
+		}
+	}
+
+	if ramp, ok := feature.Ramp(); ok {
+		state = append(state, fmt.Sprintf("ramp=%d steps", len(ramp.Steps)))
+	}
+
+	return strings.Join(state, " ")
+}
+
+func setMetadataFeatureFlag(c *cli.Context) error {
+	ff := rollout.NewFeature(c.Args().Get(0))
+	if ff.Name() == "" {
+		return cli.NewExitError("Missing required feature flag name", 1)
+	}
+
+	change := rollout.MetadataChange{
+		AddTags:    c.StringSlice("add-tag"),
+		RemoveTags: c.StringSlice("remove-tag"),
+	}
+	if c.IsSet("description") {
+		description := c.String("description")
+		change.Description = &description
+	}
+	if c.IsSet("owner") {
+		owner := c.String("owner")
+		change.Owner = &owner
+	}
+
+	return newManager(c).ChangeMetadataContext(c.Context, ff, change)
+}
+
 func activatePercentageFeatureFlag(c *cli.Context) error {
 	ff := rollout.NewFeature(c.Args().Get(0))
 	if ff.Name() == "" {
@@ -174,22 +688,35 @@ func activatePercentageFeatureFlag(c *cli.Context) error {
 		return cli.NewExitError("Missing required percentage", 1)
 	}
 
-	percentage, err := strconv.ParseUint(percentageStr, 10, 8)
+	basisPoints, err := parseBasisPoints(percentageStr)
 	if err != nil {
 		return err
 	}
 
-	manager := rollout.NewManager(
-		redis.NewUniversalClient(
-			&redis.UniversalOptions{
-				Addrs: strings.Split(c.String("host"), ","),
-			},
-		),
-		c.String("prefix"),
-		false,
-	)
+	return newManager(c).ActivateBasisPointsContext(c.Context, ff, basisPoints)
+}
+
+// parseBasisPoints parses a percentage with up to two decimals, e.g. 0.05, into basis points
+func parseBasisPoints(percentageStr string) (uint16, error) {
+	percentage, err := strconv.ParseFloat(percentageStr, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	basisPoints := math.Round(percentage * 100)
+	if basisPoints < 0 || basisPoints > rollout.MaxBasisPoints {
+		return 0, cli.NewExitError("Percentage must be between 0 and 100", 1)
+	}
+	if math.Abs(percentage*100-basisPoints) > 1e-6 {
+		return 0, cli.NewExitError("Percentage must have at most two decimals", 1)
+	}
+
+	return uint16(basisPoints), nil
+}
 
-	return manager.ActivatePercentage(ff, uint8(percentage))
+// formatBasisPoints formats basis points as a percentage, e.g. 0.05
+func formatBasisPoints(basisPoints uint16) string {
+	return strconv.FormatFloat(float64(basisPoints)/100, 'f', -1, 64)
 }
 
 func activateFeatureFlag(c *cli.Context) error {
@@ -198,17 +725,7 @@ func activateFeatureFlag(c *cli.Context) error {
 		return cli.NewExitError("Missing required feature flag name", 1)
 	}
 
-	manager := rollout.NewManager(
-		redis.NewUniversalClient(
-			&redis.UniversalOptions{
-				Addrs: strings.Split(c.String("host"), ","),
-			},
-		),
-		c.String("prefix"),
-		false,
-	)
-
-	return manager.Activate(ff)
+	return newManager(c).ActivateContext(c.Context, ff)
 }
 
 func deactivateFeatureFlag(c *cli.Context) error {
@@ -217,17 +734,7 @@ func deactivateFeatureFlag(c *cli.Context) error {
 		return cli.NewExitError("Missing required feature flag name", 1)
 	}
 
-	manager := rollout.NewManager(
-		redis.NewUniversalClient(
-			&redis.UniversalOptions{
-				Addrs: strings.Split(c.String("host"), ","),
-			},
-		),
-		c.String("prefix"),
-		false,
-	)
-
-	return manager.Deactivate(ff)
+	return newManager(c).DeactivateContext(c.Context, ff)
 }
 
 func activateTeamFeatureFlag(c *cli.Context) error {
@@ -246,17 +753,7 @@ func activateTeamFeatureFlag(c *cli.Context) error {
 		return err
 	}
 
-	manager := rollout.NewManager(
-		redis.NewUniversalClient(
-			&redis.UniversalOptions{
-				Addrs: strings.Split(c.String("host"), ","),
-			},
-		),
-		c.String("prefix"),
-		false,
-	)
-
-	return manager.ActivateTeam(teamID, ff)
+	return newManager(c).ActivateTeamContext(c.Context, teamID, ff)
 }
 
 func deactivateTeamFeatureFlag(c *cli.Context) error {
@@ -275,38 +772,835 @@ func deactivateTeamFeatureFlag(c *cli.Context) error {
 		return err
 	}
 
-	manager := rollout.NewManager(
-		redis.NewUniversalClient(
-			&redis.UniversalOptions{
-				Addrs: strings.Split(c.String("host"), ","),
-			},
-		),
-		c.String("prefix"),
-		false,
-	)
-
-	return manager.DeactivateTeam(teamID, ff)
+	return newManager(c).DeactivateTeamContext(c.Context, teamID, ff)
 }
 
-func deleteFeatureFlag(c *cli.Context) error {
+func blockTeamFeatureFlag(c *cli.Context) error {
 	ff := rollout.NewFeature(c.Args().Get(0))
 	if ff.Name() == "" {
 		return cli.NewExitError("Missing required feature flag name", 1)
 	}
 
-	client := redis.NewUniversalClient(
-		&redis.UniversalOptions{
-			Addrs: strings.Split(c.String("host"), ","),
-		},
-	)
+	teamIDStr := c.Args().Get(1)
+	if teamIDStr == "" {
+		return cli.NewExitError("Missing required team id", 1)
+	}
 
-	count, err := client.Del(c.String("prefix") + ":" + ff.Name()).Result()
+	teamID, err := strconv.ParseInt(teamIDStr, 10, 64)
 	if err != nil {
 		return err
 	}
-	if count == 0 {
-		return cli.NewExitError("Feature flag was not found", 0)
-	}
+
+	return newManager(c).BlockTeamContext(c.Context, teamID, ff)
+}
+
+func unblockTeamFeatureFlag(c *cli.Context) error {
+	ff := rollout.NewFeature(c.Args().Get(0))
+	if ff.Name() == "" {
+		return cli.NewExitError("Missing required feature flag name", 1)
+	}
+
+	teamIDStr := c.Args().Get(1)
+	if teamIDStr == "" {
+		return cli.NewExitError("Missing required team id", 1)
+	}
+
+	teamID, err := strconv.ParseInt(teamIDStr, 10, 64)
+	if err != nil {
+		return err
+	}
+
+	return newManager(c).UnblockTeamContext(c.Context, teamID, ff)
+}
+
+// findFeatureFlag returns the persisted feature flag with the given name
+func findFeatureFlag(c *cli.Context, name string) (*rollout.Feature, error) {
+	feature, err := newManager(c).GetContext(c.Context, rollout.NewFeature(name))
+	if err != nil {
+		return nil, err
+	}
+	if feature == nil {
+		return nil, cli.NewExitError("Feature flag was not found", 1)
+	}
+
+	return feature, nil
+}
+
+func listRulesFeatureFlag(c *cli.Context) error {
+	name := c.Args().Get(0)
+	if name == "" {
+		return cli.NewExitError("Missing required feature flag name", 1)
+	}
+
+	ff, err := findFeatureFlag(c, name)
+	if err != nil {
+		return err
+	}
+
+	w := newTable()
+	defer w.Flush()
+
+	fmt.Fprintf(w, " %s\t%s\t", "index", "rule")
+	fmt.Fprintf(w, "\n %s\t%s\t", "-----", "----")
+
+	for i, rule := range ff.Rules() {
+		fmt.Fprintf(w, "\n %d\t%s\t", i, rule)
+	}
+
+	fmt.Fprint(w, "\n")
+
+	return nil
+}
+
+func addRuleFeatureFlag(c *cli.Context) error {
+	ff := rollout.NewFeature(c.Args().Get(0))
+	if ff.Name() == "" {
+		return cli.NewExitError("Missing required feature flag name", 1)
+	}
+
+	if c.Args().Len() < 2 {
+		return cli.NewExitError("Missing required clause", 1)
+	}
+
+	var rule rollout.Rule
+	for _, str := range c.Args().Slice()[1:] {
+		clause, err := rollout.ParseClause(str)
+		if err != nil {
+			return err
+		}
+		rule = append(rule, clause)
+	}
+
+	return newManager(c).AddRuleContext(c.Context, ff, rule)
+}
+
+func removeRuleFeatureFlag(c *cli.Context) error {
+	name := c.Args().Get(0)
+	if name == "" {
+		return cli.NewExitError("Missing required feature flag name", 1)
+	}
+
+	indexStr := c.Args().Get(1)
+	if indexStr == "" {
+		return cli.NewExitError("Missing required index", 1)
+	}
+
+	index, err := strconv.Atoi(indexStr)
+	if err != nil {
+		return err
+	}
+
+	ff, err := findFeatureFlag(c, name)
+	if err != nil {
+		return err
+	}
+	rules := ff.Rules()
+	if index < 0 || index >= len(rules) {
+		return cli.NewExitError("Rule was not found", 1)
+	}
+
+	// removed by its clauses, so it's the rule listed at the index even when other rules were changed since
+	return newManager(c).RemoveRuleContext(c.Context, ff, rules[index])
+}
+
+func setVariantFeatureFlag(c *cli.Context) error {
+	ff := rollout.NewFeature(c.Args().Get(0))
+	if ff.Name() == "" {
+		return cli.NewExitError("Missing required feature flag name", 1)
+	}
+
+	variant := c.Args().Get(1)
+	if variant == "" {
+		return cli.NewExitError("Missing required variant", 1)
+	}
+
+	weightStr := c.Args().Get(2)
+	if weightStr == "" {
+		return cli.NewExitError("Missing required weight", 1)
+	}
+
+	weight, err := strconv.ParseUint(weightStr, 10, 32)
+	if err != nil {
+		return err
+	}
+
+	return newManager(c).SetVariantContext(c.Context, ff, variant, uint32(weight))
+}
+
+func removeVariantFeatureFlag(c *cli.Context) error {
+	ff := rollout.NewFeature(c.Args().Get(0))
+	if ff.Name() == "" {
+		return cli.NewExitError("Missing required feature flag name", 1)
+	}
+
+	variant := c.Args().Get(1)
+	if variant == "" {
+		return cli.NewExitError("Missing required variant", 1)
+	}
+
+	return newManager(c).RemoveVariantContext(c.Context, ff, variant)
+}
+
+func listPayloadsFeatureFlag(c *cli.Context) error {
+	name := c.Args().Get(0)
+	if name == "" {
+		return cli.NewExitError("Missing required feature flag name", 1)
+	}
+
+	ff, err := findFeatureFlag(c, name)
+	if err != nil {
+		return err
+	}
+
+	payloads := ff.Payloads()
+	variants := make([]string, 0, len(payloads))
+	for variant := range payloads {
+		variants = append(variants, variant)
+	}
+	sort.Strings(variants)
+
+	w := newTable()
+	defer w.Flush()
+
+	fmt.Fprintf(w, " %s\t%s\t%s\t", "type", "value", "variant")
+	fmt.Fprintf(w, "\n %s\t%s\t%s\t", "----", "-----", "-------")
+
+	for _, variant := range variants {
+		fmt.Fprintf(w, "\n %s\t%s\t%s\t", payloads[variant].Type, payloads[variant].Value, variant)
+	}
+
+	fmt.Fprint(w, "\n")
+
+	return nil
+}
+
+func setPayloadFeatureFlag(c *cli.Context) error {
+	ff := rollout.NewFeature(c.Args().Get(0))
+	if ff.Name() == "" {
+		return cli.NewExitError("Missing required feature flag name", 1)
+	}
+
+	typ := c.Args().Get(1)
+	if typ == "" {
+		return cli.NewExitError("Missing required payload type", 1)
+	}
+
+	if c.Args().Len() < 3 {
+		return cli.NewExitError("Missing required payload value", 1)
+	}
+
+	payload, err := rollout.ParsePayload(rollout.PayloadType(typ), c.Args().Get(2))
+	if err != nil {
+		return err
+	}
+
+	return newManager(c).SetPayloadContext(c.Context, ff, c.String("variant"), payload)
+}
+
+func removePayloadFeatureFlag(c *cli.Context) error {
+	ff := rollout.NewFeature(c.Args().Get(0))
+	if ff.Name() == "" {
+		return cli.NewExitError("Missing required feature flag name", 1)
+	}
+
+	return newManager(c).RemovePayloadContext(c.Context, ff, c.String("variant"))
+}
+
+func listScheduledChanges(c *cli.Context) error {
+	features, err := newManager(c).ListContext(c.Context)
+	if err != nil {
+		return err
+	}
+
+	w := newTable()
+	defer w.Flush()
+
+	fmt.Fprintf(w, " %s\t%s\t%s\t%s\t", "flag", "index", "at", "change")
+	fmt.Fprintf(w, "\n %s\t%s\t%s\t%s\t", "----", "-----", "--", "------")
+
+	for _, feature := range features {
+		for i, change := range feature.Schedule() {
+			fmt.Fprintf(w, "\n %s\t%d\t%s\t%s\t", feature.Name(), i, change.At.Format(time.RFC3339), change)
+		}
+	}
+
+	fmt.Fprint(w, "\n")
+
+	return nil
+}
+
+// scheduleArgs parses the feature flag name and time arguments of the schedule commands
+func scheduleArgs(c *cli.Context) (*rollout.Feature, time.Time, error) {
+	ff := rollout.NewFeature(c.Args().Get(0))
+	if ff.Name() == "" {
+		return nil, time.Time{}, cli.NewExitError("Missing required feature flag name", 1)
+	}
+
+	atStr := c.Args().Get(1)
+	if atStr == "" {
+		return nil, time.Time{}, cli.NewExitError("Missing required time", 1)
+	}
+
+	at, err := time.Parse(time.RFC3339, atStr)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	return ff, at, nil
+}
+
+func scheduleActivateFeatureFlag(c *cli.Context) error {
+	ff, at, err := scheduleArgs(c)
+	if err != nil {
+		return err
+	}
+
+	return newManager(c).ScheduleActivationContext(c.Context, ff, at)
+}
+
+func scheduleDeactivateFeatureFlag(c *cli.Context) error {
+	ff, at, err := scheduleArgs(c)
+	if err != nil {
+		return err
+	}
+
+	return newManager(c).ScheduleDeactivationContext(c.Context, ff, at)
+}
+
+func schedulePercentageFeatureFlag(c *cli.Context) error {
+	ff, at, err := scheduleArgs(c)
+	if err != nil {
+		return err
+	}
+
+	percentageStr := c.Args().Get(2)
+	if percentageStr == "" {
+		return cli.NewExitError("Missing required percentage", 1)
+	}
+
+	basisPoints, err := parseBasisPoints(percentageStr)
+	if err != nil {
+		return err
+	}
+
+	return newManager(c).ScheduleBasisPointsContext(c.Context, ff, at, basisPoints)
+}
+
+func unscheduleFeatureFlag(c *cli.Context) error {
+	name := c.Args().Get(0)
+	if name == "" {
+		return cli.NewExitError("Missing required feature flag name", 1)
+	}
+
+	indexStr := c.Args().Get(1)
+	if indexStr == "" {
+		return cli.NewExitError("Missing required index", 1)
+	}
+
+	index, err := strconv.Atoi(indexStr)
+	if err != nil {
+		return err
+	}
+
+	ff, err := findFeatureFlag(c, name)
+	if err != nil {
+		return err
+	}
+	schedule := ff.Schedule()
+	if index < 0 || index >= len(schedule) {
+		return cli.NewExitError("Scheduled change was not found", 1)
+	}
+
+	// cancelled by its time and what it does, so it's the change listed at the index even when the schedule was changed since
+	return newManager(c).UnscheduleChangeContext(c.Context, ff, schedule[index])
+}
+
+func showRampFeatureFlag(c *cli.Context) error {
+	name := c.Args().Get(0)
+	if name == "" {
+		return cli.NewExitError("Missing required feature flag name", 1)
+	}
+
+	ff, err := findFeatureFlag(c, name)
+	if err != nil {
+		return err
+	}
+
+	ramp, ok := ff.Ramp()
+	if !ok {
+		return cli.NewExitError("Feature flag isn't ramping", 1)
+	}
+
+	current := ramp.StepAt(time.Now())
+	startsAt := ramp.StartedAt
+
+	w := newTable()
+	defer w.Flush()
+
+	fmt.Fprintf(w, " %s\t%s\t%s\t%s\t%s\t", "step", "percentage", "starts_at", "status", "duration")
+	fmt.Fprintf(w, "\n %s\t%s\t%s\t%s\t%s\t", "----", "----------", "---------", "------", "--------")
+
+	for i, step := range ramp.Steps {
+		status := "pending"
+		if i < current {
+			status = "done"
+		} else if i == current && ramp.Paused() {
+			status = "paused"
+		} else if i == current {
+			status = "current"
+		}
+
+		// steps after the one the ramp is paused at start whenever it's resumed
+		startsAtStr := startsAt.Format(time.RFC3339)
+		if ramp.Paused() && i > current {
+			startsAtStr = ""
+		}
+
+		fmt.Fprintf(w, "\n %d\t%s\t%s\t%s\t%s\t", i, formatBasisPoints(step.BasisPoints), startsAtStr, status, step.Duration)
+		startsAt = startsAt.Add(step.Duration)
+	}
+
+	fmt.Fprint(w, "\n")
+
+	return nil
+}
+
+// parseRampStep parses a ramp step formatted as percentage:duration, e.g. 5:24h, the duration being optional
+func parseRampStep(str string) (rollout.RampStep, error) {
+	percentageStr, durationStr, _ := strings.Cut(str, ":")
+
+	basisPoints, err := parseBasisPoints(percentageStr)
+	if err != nil {
+		return rollout.RampStep{}, err
+	}
+
+	var duration time.Duration
+	if durationStr != "" {
+		if duration, err = time.ParseDuration(durationStr); err != nil {
+			return rollout.RampStep{}, err
+		}
+	}
+
+	return rollout.RampStep{BasisPoints: basisPoints, Duration: duration}, nil
+}
+
+func startRampFeatureFlag(c *cli.Context) error {
+	ff := rollout.NewFeature(c.Args().Get(0))
+	if ff.Name() == "" {
+		return cli.NewExitError("Missing required feature flag name", 1)
+	}
+
+	if c.Args().Len() < 2 {
+		return cli.NewExitError("Missing required ramp step", 1)
+	}
+
+	var steps []rollout.RampStep
+	for _, str := range c.Args().Slice()[1:] {
+		step, err := parseRampStep(str)
+		if err != nil {
+			return err
+		}
+		steps = append(steps, step)
+	}
+
+	return newManager(c).StartRampContext(c.Context, ff, steps...)
+}
+
+// rampingFeatureFlag returns the persisted feature flag with the name given as the first argument, which must be ramping
+func rampingFeatureFlag(c *cli.Context) (*rollout.Feature, error) {
+	name := c.Args().Get(0)
+	if name == "" {
+		return nil, cli.NewExitError("Missing required feature flag name", 1)
+	}
+
+	ff, err := findFeatureFlag(c, name)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := ff.Ramp(); !ok {
+		return nil, cli.NewExitError("Feature flag isn't ramping", 1)
+	}
+
+	return ff, nil
+}
+
+func pauseRampFeatureFlag(c *cli.Context) error {
+	ff, err := rampingFeatureFlag(c)
+	if err != nil {
+		return err
+	}
+
+	return newManager(c).PauseRampContext(c.Context, ff)
+}
+
+func resumeRampFeatureFlag(c *cli.Context) error {
+	ff, err := rampingFeatureFlag(c)
+	if err != nil {
+		return err
+	}
+
+	return newManager(c).ResumeRampContext(c.Context, ff)
+}
+
+func abortRampFeatureFlag(c *cli.Context) error {
+	ff, err := rampingFeatureFlag(c)
+	if err != nil {
+		return err
+	}
+
+	return newManager(c).AbortRampContext(c.Context, ff)
+}
+
+func showDependencies(c *cli.Context) error {
+	features, err := newManager(c).ListContext(c.Context)
+	if err != nil {
+		return err
+	}
+
+	byName := make(map[string]*rollout.Feature, len(features))
+	dependedOn := make(map[string]bool)
+	for _, feature := range features {
+		byName[feature.Name()] = feature
+		for _, prerequisite := range feature.Prerequisites() {
+			dependedOn[prerequisite] = true
+		}
+	}
+
+	if name := c.Args().Get(0); name != "" {
+		if byName[name] == nil {
+			return cli.NewExitError("Feature flag was not found", 1)
+		}
+
+		printDependencies(byName, name, 0, nil)
+		return nil
+	}
+
+	// print the features nothing depends on first, then the cycles which have none
+	printed := make(map[string]bool)
+	for _, root := range []bool{true, false} {
+		for _, feature := range features {
+			name := feature.Name()
+			if len(feature.Prerequisites()) == 0 || dependedOn[name] == root || printed[name] {
+				continue
+			}
+
+			for _, dependency := range printDependencies(byName, name, 0, nil) {
+				printed[dependency] = true
+			}
+		}
+	}
+
+	return nil
+}
+
+// printDependencies prints the feature flag indented by its depth, followed by its prerequisites, returning the names
+// of the printed feature flags. The path holds the feature flags depending on the feature flag, to stop at cycles.
+func printDependencies(features map[string]*rollout.Feature, name string, depth int, path []string) []string {
+	indent := strings.Repeat("  ", depth)
+
+	for _, dependent := range path {
+		if dependent == name {
+			fmt.Printf("%s%s (cycle)\n", indent, name)
+			return nil
+		}
+	}
+
+	feature := features[name]
+	if feature == nil {
+		fmt.Printf("%s%s (missing)\n", indent, name)
+		return nil
+	}
+
+	fmt.Printf("%s%s\n", indent, name)
+
+	printed := []string{name}
+	for _, prerequisite := range feature.Prerequisites() {
+		printed = append(printed, printDependencies(features, prerequisite, depth+1, append(path, name))...)
+	}
+
+	return printed
+}
+
+func addPrerequisiteFeatureFlag(c *cli.Context) error {
+	ff := rollout.NewFeature(c.Args().Get(0))
+	if ff.Name() == "" {
+		return cli.NewExitError("Missing required feature flag name", 1)
+	}
+
+	prerequisite := c.Args().Get(1)
+	if prerequisite == "" {
+		return cli.NewExitError("Missing required prerequisite name", 1)
+	}
+
+	return newManager(c).AddPrerequisiteContext(c.Context, ff, prerequisite)
+}
+
+func removePrerequisiteFeatureFlag(c *cli.Context) error {
+	ff := rollout.NewFeature(c.Args().Get(0))
+	if ff.Name() == "" {
+		return cli.NewExitError("Missing required feature flag name", 1)
+	}
+
+	prerequisite := c.Args().Get(1)
+	if prerequisite == "" {
+		return cli.NewExitError("Missing required prerequisite name", 1)
+	}
+
+	return newManager(c).RemovePrerequisiteContext(c.Context, ff, prerequisite)
+}
+
+func setSaltFeatureFlag(c *cli.Context) error {
+	ff := rollout.NewFeature(c.Args().Get(0))
+	if ff.Name() == "" {
+		return cli.NewExitError("Missing required feature flag name", 1)
+	}
+
+	return newManager(c).SetSaltContext(c.Context, ff, c.Args().Get(1))
+}
+
+func setHashFeatureFlag(c *cli.Context) error {
+	ff := rollout.NewFeature(c.Args().Get(0))
+	if ff.Name() == "" {
+		return cli.NewExitError("Missing required feature flag name", 1)
+	}
+
+	hash := c.Args().Get(1)
+	if hash == "" {
+		return cli.NewExitError("Missing required hash", 1)
+	}
+
+	return newManager(c).SetHashContext(c.Context, ff, rollout.Hash(hash))
+}
+
+// actorArg parses the actor given as the kind and id arguments following the feature name
+func actorArg(c *cli.Context) (rollout.Actor, error) {
+	kind := c.Args().Get(1)
+	if kind == "" {
+		return rollout.Actor{}, cli.NewExitError("Missing required actor kind", 1)
+	}
+
+	id := c.Args().Get(2)
+	if id == "" {
+		return rollout.Actor{}, cli.NewExitError("Missing required actor id", 1)
+	}
+
+	return rollout.NewActor(rollout.ActorKind(kind), id), nil
+}
+
+func activateActorFeatureFlag(c *cli.Context) error {
+	ff := rollout.NewFeature(c.Args().Get(0))
+	if ff.Name() == "" {
+		return cli.NewExitError("Missing required feature flag name", 1)
+	}
+
+	actor, err := actorArg(c)
+	if err != nil {
+		return err
+	}
+
+	return newManager(c).ActivateActorContext(c.Context, actor, ff)
+}
+
+func deactivateActorFeatureFlag(c *cli.Context) error {
+	ff := rollout.NewFeature(c.Args().Get(0))
+	if ff.Name() == "" {
+		return cli.NewExitError("Missing required feature flag name", 1)
+	}
+
+	actor, err := actorArg(c)
+	if err != nil {
+		return err
+	}
+
+	return newManager(c).DeactivateActorContext(c.Context, actor, ff)
+}
+
+func activateActorPercentageFeatureFlag(c *cli.Context) error {
+	ff := rollout.NewFeature(c.Args().Get(0))
+	if ff.Name() == "" {
+		return cli.NewExitError("Missing required feature flag name", 1)
+	}
+
+	kind := c.Args().Get(1)
+	if kind == "" {
+		return cli.NewExitError("Missing required actor kind", 1)
+	}
+
+	percentageStr := c.Args().Get(2)
+	if percentageStr == "" {
+		return cli.NewExitError("Missing required percentage", 1)
+	}
+
+	percentage, err := strconv.ParseUint(percentageStr, 10, 8)
+	if err != nil {
+		return err
+	}
+
+	return newManager(c).ActivateActorPercentageContext(c.Context, rollout.ActorKind(kind), ff, uint8(percentage))
+}
+
+func activateSegmentFeatureFlag(c *cli.Context) error {
+	ff := rollout.NewFeature(c.Args().Get(0))
+	if ff.Name() == "" {
+		return cli.NewExitError("Missing required feature flag name", 1)
+	}
+
+	segment := c.Args().Get(1)
+	if segment == "" {
+		return cli.NewExitError("Missing required segment name", 1)
+	}
+
+	return newManager(c).ActivateSegmentContext(c.Context, segment, ff)
+}
+
+func deactivateSegmentFeatureFlag(c *cli.Context) error {
+	ff := rollout.NewFeature(c.Args().Get(0))
+	if ff.Name() == "" {
+		return cli.NewExitError("Missing required feature flag name", 1)
+	}
+
+	segment := c.Args().Get(1)
+	if segment == "" {
+		return cli.NewExitError("Missing required segment name", 1)
+	}
+
+	return newManager(c).DeactivateSegmentContext(c.Context, segment, ff)
+}
+
+func listSegments(c *cli.Context) error {
+	segments, err := newManager(c).SegmentsContext(c.Context)
+	if err != nil {
+		return err
+	}
+
+	w := newTable()
+	defer w.Flush()
+
+	fmt.Fprintf(w, " %s\t%s\t", "segment", "teams")
+	fmt.Fprintf(w, "\n %s\t%s\t", "-------", "-----")
+
+	for _, segment := range segments {
+		teamIDs := make([]string, 0)
+		for _, teamID := range segment.TeamIDs() {
+			teamIDs = append(teamIDs, strconv.FormatInt(teamID, 10))
+		}
+
+		fmt.Fprintf(w, "\n %s\t%s\t", segment.Name(), strings.Join(teamIDs, ","))
+	}
+
+	fmt.Fprint(w, "\n")
+
+	return nil
+}
+
+// segmentTeamsArgs parses the segment name and team ids arguments
+func segmentTeamsArgs(c *cli.Context) (string, []int64, error) {
+	segment := c.Args().Get(0)
+	if segment == "" {
+		return "", nil, cli.NewExitError("Missing required segment name", 1)
+	}
+
+	if c.Args().Len() < 2 {
+		return "", nil, cli.NewExitError("Missing required team id", 1)
+	}
+
+	teamIDs := make([]int64, 0, c.Args().Len()-1)
+	for _, teamIDStr := range c.Args().Slice()[1:] {
+		teamID, err := strconv.ParseInt(teamIDStr, 10, 64)
+		if err != nil {
+			return "", nil, err
+		}
+		teamIDs = append(teamIDs, teamID)
+	}
+
+	return segment, teamIDs, nil
+}
+
+func addSegmentTeams(c *cli.Context) error {
+	segment, teamIDs, err := segmentTeamsArgs(c)
+	if err != nil {
+		return err
+	}
+
+	return newManager(c).AddSegmentTeamsContext(c.Context, segment, teamIDs...)
+}
+
+func removeSegmentTeams(c *cli.Context) error {
+	segment, teamIDs, err := segmentTeamsArgs(c)
+	if err != nil {
+		return err
+	}
+
+	return newManager(c).RemoveSegmentTeamsContext(c.Context, segment, teamIDs...)
+}
+
+func deleteSegment(c *cli.Context) error {
+	segment := c.Args().Get(0)
+	if segment == "" {
+		return cli.NewExitError("Missing required segment name", 1)
+	}
+
+	deleted, err := newManager(c).DeleteSegmentContext(c.Context, segment)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return cli.NewExitError("Segment was not found", 0)
+	}
+
+	return nil
+}
+
+func showSegmentHistory(c *cli.Context) error {
+	segment := c.Args().Get(0)
+	if segment == "" {
+		return cli.NewExitError("Missing required segment name", 1)
+	}
+
+	history, err := newManager(c).SegmentHistoryContext(c.Context, segment)
+	if err != nil {
+		return err
+	}
+
+	w := newTable()
+	defer w.Flush()
+
+	fmt.Fprintf(w, " %s\t%s\t%s\t%s\t", "at", "author", "operation", "reason")
+	fmt.Fprintf(w, "\n %s\t%s\t%s\t%s\t", "--", "------", "---------", "------")
+
+	for _, entry := range history {
+		fmt.Fprintf(w, "\n %s\t%s\t%s\t%s\t", formatTime(entry.At), entry.Author, entry.Operation, entry.Reason)
+	}
+
+	fmt.Fprint(w, "\n")
+
+	return nil
+}
+
+func deleteFeatureFlag(c *cli.Context) error {
+	ff := rollout.NewFeature(c.Args().Get(0))
+	if ff.Name() == "" {
+		return cli.NewExitError("Missing required feature flag name", 1)
+	}
+
+	deleted, err := newManager(c).DeleteContext(c.Context, ff)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return cli.NewExitError("Feature flag was not found", 0)
+	}
+
+	return nil
+}
+
+func migrateFeatureFlags(c *cli.Context) error {
+	migrated, err := newManager(c).MigrateContext(c.Context)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Migrated %d feature flags\n", migrated)
 
 	return nil
 }
@@ -1,40 +1,144 @@
 package rollout
 
 import (
-	"hash/crc32"
 	"math"
-	"strconv"
+	"sort"
 	"sync"
+	"time"
 
 	msgpack "github.com/vmihailenco/msgpack/v4"
 )
 
 const (
 	randBase = uint32((math.MaxUint32 - 1) / 100)
+
+	// MaxBasisPoints is the rollout of a feature active for every team, in hundredths of a percent
+	MaxBasisPoints = 10000
 )
 
+// FeatureOption configures optional behaviour of a Feature
+type FeatureOption func(f *Feature)
+
+// WithDefault declares whether the feature is active when it isn't persisted, e.g. for kill switches that are normally enabled
+func WithDefault(active bool) FeatureOption {
+	return func(f *Feature) {
+		f.defaultActive = active
+		f.defaultDeclared = true
+	}
+}
+
 // NewFeature constructs a new Feature with the given name
-func NewFeature(name string) *Feature {
-	return &Feature{name: name}
+func NewFeature(name string, opts ...FeatureOption) *Feature {
+	f := &Feature{name: name}
+
+	for _, opt := range opts {
+		opt(f)
+	}
+
+	f.reset()
+
+	return f
 }
 
 // Feature represents a development feature toggle for rollout
 type Feature struct {
 	sync.Mutex
 
-	name       string // the name of the feature
-	percentage uint8  // the rollout percentage
-	teamIDs    intSet // explicit team ids with the feature enabled
+	name            string // the name of the feature
+	defaultActive   bool   // whether the feature is active when it isn't persisted
+	defaultDeclared bool   // whether the default was declared, rather than decoded
+	percentage      uint8  // the rollout percentage of teams, 100 meaning the feature is globally active
+	fraction        uint8  // the basis points rolled out on top of the percentage, for rollouts finer than 1%
+	teamIDs         intSet // explicit team ids with the feature enabled
+
+	actors           map[ActorKind]stringSet // explicit actor ids with the feature enabled, by kind
+	actorPercentages map[ActorKind]uint8     // the rollout percentage of actors other than teams, by kind
+
+	segments       stringSet // names of the segments of teams with the feature enabled
+	blockedTeamIDs intSet    // team ids with the feature disabled, regardless of any other activation
+
+	salt string // prefixes the actor ids when bucketing, instead of the feature name when randomizing percentage
+	hash Hash   // the algorithm actors are bucketed with, empty for the default
+
+	rules    []Rule             // targeting rules activating the feature for matching attributes
+	variants []Variant          // weighted variants assigned to the actors the feature is active for
+	payloads map[string]Payload // values served along with the variants, by variant name, empty for no variant
+
+	schedule []ScheduledChange // changes made to the feature at a time, ordered by time
+	ramp     *Ramp             // progressively rolls the feature out, replacing the rollout percentage until it completes
+
+	prerequisites stringSet // names of the features which must be active for the feature to be active
+
+	metadata Metadata // describes the feature, and when and by whom it was changed
+	version  int64    // the number of changes made to the feature, identifying its entries in the history
+}
+
+// load updates the feature to align with the given data, nil data meaning the feature isn't persisted
+func (f *Feature) load(data []byte) error {
+	if data == nil {
+		// feature isn't persisted, so should be in its default state
+		f.reset()
+		return nil
+	}
+
+	return msgpack.Unmarshal(data, f)
+}
+
+// blank returns a copy of the feature in the state it has when it isn't persisted
+func (f *Feature) blank() *Feature {
+	state := &Feature{
+		name:            f.name,
+		defaultActive:   f.defaultActive,
+		defaultDeclared: f.defaultDeclared,
+	}
+	state.reset()
+
+	return state
 }
 
-// EncodeMsgpack implements msgpack.CustomEncoder
-func (f *Feature) EncodeMsgpack(enc *msgpack.Encoder) error {
-	return enc.EncodeMulti(f.percentage, f.teamIDs)
+// clone returns a copy of the feature sharing its sets, maps and slices, which must not be modified
+func (f *Feature) clone() *Feature {
+	return &Feature{
+		name:             f.name,
+		defaultActive:    f.defaultActive,
+		defaultDeclared:  f.defaultDeclared,
+		percentage:       f.percentage,
+		fraction:         f.fraction,
+		teamIDs:          f.teamIDs,
+		actors:           f.actors,
+		actorPercentages: f.actorPercentages,
+		segments:         f.segments,
+		blockedTeamIDs:   f.blockedTeamIDs,
+		salt:             f.salt,
+		hash:             f.hash,
+		rules:            f.rules,
+		variants:         f.variants,
+		payloads:         f.payloads,
+		schedule:         f.schedule,
+		ramp:             f.ramp,
+		prerequisites:    f.prerequisites,
+		metadata:         f.metadata,
+		version:          f.version,
+	}
 }
 
-// DecodeMsgpack implements msgpack.CustomDecoder
-func (f *Feature) DecodeMsgpack(dec *msgpack.Decoder) error {
-	return dec.DecodeMulti(&f.percentage, &f.teamIDs)
+// advance makes the scheduled changes which are due at the time, and rolls the feature out the percentage its ramp is at
+func (f *Feature) advance(now time.Time) {
+	f.applySchedule(now)
+	f.applyRamp(now)
+}
+
+// at returns the state of the feature at the time, which is the feature itself unless scheduled changes are due
+// or it's ramping, and otherwise an advanced copy. Like the feature, it must not be modified.
+func (f *Feature) at(now time.Time) *Feature {
+	if f.due(now) == 0 && f.ramp == nil {
+		return f
+	}
+
+	state := f.clone()
+	state.advance(now)
+
+	return state
 }
 
 // Name returns the name of the feature
@@ -42,17 +146,268 @@ func (f *Feature) Name() string {
 	return f.name
 }
 
+// Default returns whether the feature is active when it isn't persisted
+func (f *Feature) Default() bool {
+	return f.defaultActive
+}
+
+// Percentage returns the whole rollout percentage of teams, 100 meaning the feature is globally active
+func (f *Feature) Percentage() uint8 {
+	f.Lock()
+	defer f.Unlock()
+
+	return f.percentage
+}
+
+// BasisPoints returns the rollout of teams in hundredths of a percent, including any fraction of a percent
+func (f *Feature) BasisPoints() uint16 {
+	f.Lock()
+	defer f.Unlock()
+
+	return f.basisPoints()
+}
+
+// TeamIDs returns the ids of the teams the feature is explicitly active for, in ascending order
+func (f *Feature) TeamIDs() []int64 {
+	f.Lock()
+	defer f.Unlock()
+
+	teamIDs := make([]int64, 0, len(f.teamIDs))
+	for teamID := range f.teamIDs {
+		teamIDs = append(teamIDs, teamID)
+	}
+	sort.Slice(teamIDs, func(i, j int) bool { return teamIDs[i] < teamIDs[j] })
+
+	return teamIDs
+}
+
+// Actors returns the actors the feature is explicitly active for, other than the teams returned by TeamIDs,
+// ordered by kind and id
+func (f *Feature) Actors() []Actor {
+	f.Lock()
+	defer f.Unlock()
+
+	var actors []Actor
+	for kind, ids := range f.actors {
+		for id := range ids {
+			actors = append(actors, NewActor(kind, id))
+		}
+	}
+	sort.Slice(actors, func(i, j int) bool { return actors[i].String() < actors[j].String() })
+
+	return actors
+}
+
+// ActorPercentage returns the rollout percentage of actors of the kind, the percentage of teams being Percentage
+func (f *Feature) ActorPercentage(kind ActorKind) uint8 {
+	f.Lock()
+	defer f.Unlock()
+
+	return f.actorPercentage(kind)
+}
+
+// ActorKinds returns the kinds of actors, other than teams, the feature is rolled out to a percentage of, in order
+func (f *Feature) ActorKinds() []ActorKind {
+	f.Lock()
+	defer f.Unlock()
+
+	kinds := make([]ActorKind, 0, len(f.actorPercentages))
+	for kind := range f.actorPercentages {
+		kinds = append(kinds, kind)
+	}
+	sort.Slice(kinds, func(i, j int) bool { return kinds[i] < kinds[j] })
+
+	return kinds
+}
+
+// Segments returns the names of the segments of teams the feature is active for, in order
+func (f *Feature) Segments() []string {
+	f.Lock()
+	defer f.Unlock()
+
+	segments := make([]string, 0, len(f.segments))
+	for name := range f.segments {
+		segments = append(segments, name)
+	}
+	sort.Strings(segments)
+
+	return segments
+}
+
+// BlockedTeamIDs returns the ids of the teams the feature is never active for, in ascending order
+func (f *Feature) BlockedTeamIDs() []int64 {
+	f.Lock()
+	defer f.Unlock()
+
+	teamIDs := make([]int64, 0, len(f.blockedTeamIDs))
+	for teamID := range f.blockedTeamIDs {
+		teamIDs = append(teamIDs, teamID)
+	}
+	sort.Slice(teamIDs, func(i, j int) bool { return teamIDs[i] < teamIDs[j] })
+
+	return teamIDs
+}
+
+// Salt returns the salt actor ids are prefixed with when bucketing, empty when the feature has none
+func (f *Feature) Salt() string {
+	f.Lock()
+	defer f.Unlock()
+
+	return f.salt
+}
+
+// Hash returns the algorithm actors are bucketed with
+func (f *Feature) Hash() Hash {
+	f.Lock()
+	defer f.Unlock()
+
+	if f.hash == "" {
+		return HashCRC32
+	}
+
+	return f.hash
+}
+
+// Rules returns the targeting rules activating the feature for matching attributes
+func (f *Feature) Rules() []Rule {
+	f.Lock()
+	defer f.Unlock()
+
+	return append([]Rule(nil), f.rules...)
+}
+
+// Variants returns the weighted variants assigned to the actors the feature is active for
+func (f *Feature) Variants() []Variant {
+	f.Lock()
+	defer f.Unlock()
+
+	return append([]Variant(nil), f.variants...)
+}
+
+// Payloads returns the values served along with the variants, by variant name, the empty name being
+// the value served when the feature has no variants or the assigned variant has no payload
+func (f *Feature) Payloads() map[string]Payload {
+	f.Lock()
+	defer f.Unlock()
+
+	payloads := make(map[string]Payload, len(f.payloads))
+	for variant, payload := range f.payloads {
+		payloads[variant] = payload
+	}
+
+	return payloads
+}
+
+// Schedule returns the changes scheduled to be made to the feature, ordered by time
+func (f *Feature) Schedule() []ScheduledChange {
+	f.Lock()
+	defer f.Unlock()
+
+	return append([]ScheduledChange(nil), f.schedule...)
+}
+
+// Ramp returns the ramp progressively rolling the feature out, and whether the feature is ramping
+func (f *Feature) Ramp() (Ramp, bool) {
+	f.Lock()
+	defer f.Unlock()
+
+	if f.ramp == nil {
+		return Ramp{}, false
+	}
+
+	ramp := *f.ramp
+	ramp.Steps = append([]RampStep(nil), ramp.Steps...)
+
+	return ramp, true
+}
+
+// Prerequisites returns the names of the features which must be active for the feature to be active, sorted
+func (f *Feature) Prerequisites() []string {
+	f.Lock()
+	defer f.Unlock()
+
+	prerequisites := make([]string, 0, len(f.prerequisites))
+	for name := range f.prerequisites {
+		prerequisites = append(prerequisites, name)
+	}
+	sort.Strings(prerequisites)
+
+	return prerequisites
+}
+
+// Metadata returns the description, owner, tags and timestamps of the feature
+func (f *Feature) Metadata() Metadata {
+	f.Lock()
+	defer f.Unlock()
+
+	metadata := f.metadata
+	metadata.Tags = append([]string(nil), metadata.Tags...)
+
+	return metadata
+}
+
+// Version returns the number of changes made to the feature, 0 when it was never changed or is stored by older versions
+func (f *Feature) Version() int64 {
+	f.Lock()
+	defer f.Unlock()
+
+	return f.version
+}
+
+func (f *Feature) reset() {
+	f.deactivate()
+	f.blockedTeamIDs = nil
+	f.salt = ""
+	f.hash = ""
+	f.variants = nil
+	f.payloads = nil
+	f.schedule = nil
+	f.prerequisites = nil
+	f.metadata = Metadata{}
+	f.version = 0
+
+	if f.defaultActive {
+		f.activate()
+	}
+}
+
 func (f *Feature) activate() {
 	f.percentage = 100
+	f.fraction = 0
+	f.ramp = nil
 }
 
+// deactivate deactivates the feature for everyone, keeping the blocked teams, how actors are bucketed, the variants and their payloads, the scheduled changes, the prerequisites and the metadata
 func (f *Feature) deactivate() {
 	f.percentage = 0
+	f.fraction = 0
+	f.ramp = nil
 	f.teamIDs = nil
+	f.actors = nil
+	f.actorPercentages = nil
+	f.segments = nil
+	f.rules = nil
 }
 
 func (f *Feature) activatePercentage(percentage uint8) {
 	f.percentage = percentage
+	f.fraction = 0
+	f.ramp = nil
+}
+
+// activateBasisPoints activates the feature for hundredths of a percent of teams
+func (f *Feature) activateBasisPoints(basisPoints uint16) {
+	if basisPoints > MaxBasisPoints {
+		basisPoints = MaxBasisPoints
+	}
+
+	f.percentage = uint8(basisPoints / 100)
+	f.fraction = uint8(basisPoints % 100)
+	f.ramp = nil
+}
+
+func (f *Feature) basisPoints() uint16 {
+	return uint16(f.percentage)*100 + uint16(f.fraction)
 }
 
 func (f *Feature) isActive() bool {
@@ -72,21 +427,194 @@ func (f *Feature) deactivateTeam(teamID int64) {
 }
 
 func (f *Feature) isTeamActive(teamID int64, randomizePercentage bool) bool {
+	return f.evaluate(evaluation{actor: Team(teamID), randomizePercentage: randomizePercentage})
+}
+
+func (f *Feature) addRule(rule Rule) {
+	f.rules = append(f.rules, rule)
+}
+
+// removeRule removes the first rule formatted the same as the given one, reporting whether there was one
+func (f *Feature) removeRule(rule Rule) bool {
+	for i, r := range f.rules {
+		if r.String() == rule.String() {
+			f.rules = append(f.rules[:i:i], f.rules[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchesRules returns whether the attributes match any of the rules of the feature
+func (f *Feature) matchesRules(attributes Attributes) bool {
+	if attributes == nil {
+		return false
+	}
+
+	for _, rule := range f.rules {
+		if rule.matches(attributes) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (f *Feature) blockTeam(teamID int64) {
+	if f.blockedTeamIDs == nil {
+		f.blockedTeamIDs = make(intSet)
+	}
+
+	f.blockedTeamIDs[teamID] = struct{}{}
+}
+
+func (f *Feature) unblockTeam(teamID int64) {
+	delete(f.blockedTeamIDs, teamID)
+}
+
+func (f *Feature) activateSegment(name string) {
+	if f.segments == nil {
+		f.segments = make(stringSet)
+	}
+
+	f.segments[name] = struct{}{}
+}
+
+func (f *Feature) deactivateSegment(name string) {
+	delete(f.segments, name)
+}
+
+// isInSegments returns whether the team is a member of any of the given segments the feature is active for
+func (f *Feature) isInSegments(teamID int64, segments map[string]*Segment) bool {
+	for name := range f.segments {
+		if segment, ok := segments[name]; ok && segment.has(teamID) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (f *Feature) activateActor(actor Actor) {
+	if teamID, ok := actor.teamID(); ok {
+		f.activateTeam(teamID)
+		return
+	}
+
+	if f.actors == nil {
+		f.actors = make(map[ActorKind]stringSet)
+	}
+	if f.actors[actor.Kind] == nil {
+		f.actors[actor.Kind] = make(stringSet)
+	}
+
+	f.actors[actor.Kind][actor.ID] = struct{}{}
+}
+
+func (f *Feature) deactivateActor(actor Actor) {
+	if teamID, ok := actor.teamID(); ok {
+		f.deactivateTeam(teamID)
+		return
+	}
+
+	delete(f.actors[actor.Kind], actor.ID)
+	if len(f.actors[actor.Kind]) == 0 {
+		delete(f.actors, actor.Kind)
+	}
+}
+
+// activateActorPercentage activates the feature for a percentage of the kind of actors,
+// the percentage of teams being the percentage of the feature
+func (f *Feature) activateActorPercentage(kind ActorKind, percentage uint8) {
+	if kind == KindTeam {
+		f.activatePercentage(percentage)
+		return
+	}
+
+	if f.actorPercentages == nil {
+		f.actorPercentages = make(map[ActorKind]uint8)
+	}
+
+	if percentage == 0 {
+		delete(f.actorPercentages, kind)
+	} else {
+		f.actorPercentages[kind] = percentage
+	}
+}
+
+func (f *Feature) actorPercentage(kind ActorKind) uint8 {
+	if kind == KindTeam {
+		return f.percentage
+	}
+
+	return f.actorPercentages[kind]
+}
+
+// actorBasisPoints returns the rollout of the kind of actors in hundredths of a percent
+func (f *Feature) actorBasisPoints(kind ActorKind) uint16 {
+	if kind == KindTeam {
+		return f.basisPoints()
+	}
+
+	return uint16(f.actorPercentages[kind]) * 100
+}
+
+// evaluation is what a feature is evaluated for, along with the state besides the feature it depends on
+type evaluation struct {
+	actor               Actor
+	attributes          Attributes
+	randomizePercentage bool
+	segments            map[string]*Segment // the segments the feature is active for, by name
+}
+
+// evaluate returns whether the feature is active for the actor of the evaluation
+func (f *Feature) evaluate(e evaluation) bool {
+	actor := e.actor
+	teamID, isTeam := actor.teamID()
+
+	if _, blocked := f.blockedTeamIDs[teamID]; isTeam && blocked {
+		// blocked teams override every other activation
+		return false
+	}
+
 	if f.percentage == 100 {
 		// feature is globally active
 		return true
-	} else if randomizePercentage && crc32.ChecksumIEEE([]byte(f.name+strconv.FormatInt(teamID, 10))) < randBase*uint32(f.percentage) {
-		// include the feature name in the checksum when randomizing percentage
-		return true
-	} else if !randomizePercentage && crc32.ChecksumIEEE([]byte(strconv.FormatInt(teamID, 10))) < randBase*uint32(f.percentage) {
-		// only use the team id for the checksum when not randomizing the percentage
+	} else if f.hash.sum(f.bucketKey(actor, e.randomizePercentage)) < bucketThreshold(f.actorBasisPoints(actor.Kind)) {
+		// actor is bucketed within the rollout percentage
 		return true
-	} else if _, active := f.teamIDs[teamID]; active {
+	} else if _, active := f.teamIDs[teamID]; isTeam && active {
 		// check if the team is explicitly active
 		return true
+	} else if isTeam && f.isInSegments(teamID, e.segments) {
+		// check if the team is a member of a segment the feature is active for
+		return true
+	} else if _, active := f.actors[actor.Kind][actor.ID]; !isTeam && active {
+		// check if the actor is explicitly active
+		return true
 	}
 
-	return false
+	// check if the attributes match any targeting rule
+	return f.matchesRules(e.attributes)
+}
+
+// bucketKey returns the data the actor is bucketed by, which is its id prefixed by the salt of the feature.
+// Without a salt, the feature name is included when randomizing percentage, otherwise only the actor id is used.
+func (f *Feature) bucketKey(actor Actor, randomizePercentage bool) []byte {
+	if f.salt != "" {
+		return []byte(f.salt + actor.ID)
+	} else if randomizePercentage {
+		return []byte(f.name + actor.ID)
+	}
+
+	return []byte(actor.ID)
+}
+
+// bucketThreshold returns the checksum below which actors are in a rollout of the given hundredths of a percent,
+// which is the same as for the whole percentages rolled out before basis points were supported
+func bucketThreshold(basisPoints uint16) uint32 {
+	return uint32(uint64(randBase) * uint64(basisPoints) / 100)
 }
 
 // ref: https://github.com/vmihailenco/msgpack/blob/master/types_test.go#L52
@@ -1,7 +1,9 @@
 package rollout
 
 import (
+	"bytes"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/vmihailenco/msgpack/v4"
@@ -169,3 +171,360 @@ func TestRandomizePercentage(t *testing.T) {
 	assert.True(t, f.isTeamActive(teamID, true))
 	assert.False(t, f.isTeamActive(teamID, false))
 }
+
+func TestDefault(t *testing.T) {
+	f := NewFeature("example", WithDefault(true))
+	assert.True(t, f.Default())
+	assert.True(t, f.isActive())
+	assert.True(t, f.isTeamActive(1, false))
+
+	// the declared default is kept when decoding
+	data, err := msgpack.Marshal(NewFeature("example"))
+	assert.NoError(t, err)
+
+	err = msgpack.Unmarshal(data, f)
+	assert.NoError(t, err)
+	assert.True(t, f.Default())
+	assert.False(t, f.isActive())
+
+	// not persisted
+	assert.NoError(t, f.load(nil))
+	assert.True(t, f.isActive())
+
+	// the persisted default is decoded when none was declared
+	data, err = msgpack.Marshal(f)
+	assert.NoError(t, err)
+
+	out := NewFeature("example")
+	err = msgpack.Unmarshal(data, out)
+	assert.NoError(t, err)
+	assert.True(t, out.Default())
+}
+
+func TestDecodeWithoutDefault(t *testing.T) {
+	// encoded by versions before declared defaults
+	var buf bytes.Buffer
+	err := msgpack.NewEncoder(&buf).EncodeMulti(uint8(50), []int64{1, 2})
+	assert.NoError(t, err)
+
+	f := NewFeature("example", WithDefault(true))
+	err = msgpack.Unmarshal(buf.Bytes(), f)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 50, f.percentage)
+	assert.True(t, f.isTeamActive(1, false))
+	assert.True(t, f.isTeamActive(2, false))
+	assert.True(t, f.Default())
+}
+
+func TestEnableDisableActor(t *testing.T) {
+	f := NewFeature("example")
+	user := NewActor(KindUser, "abc")
+	assert.False(t, f.evaluate(evaluation{actor: user}))
+
+	f.activateActor(user)
+	assert.True(t, f.evaluate(evaluation{actor: user}))
+	assert.False(t, f.evaluate(evaluation{actor: NewActor(KindAccount, "abc")}))
+	assert.Equal(t, []Actor{user}, f.Actors())
+
+	// teams with int64 ids are the teams of the feature
+	f.activateActor(Team(1))
+	assert.True(t, f.isTeamActive(1, false))
+	assert.Equal(t, []int64{1}, f.TeamIDs())
+
+	f.activateActor(NewActor(KindTeam, "acme"))
+	assert.True(t, f.evaluate(evaluation{actor: NewActor(KindTeam, "acme")}))
+	assert.Equal(t, []Actor{NewActor(KindTeam, "acme"), user}, f.Actors())
+
+	f.deactivateActor(user)
+	f.deactivateActor(Team(1))
+	assert.False(t, f.evaluate(evaluation{actor: user}))
+	assert.False(t, f.isTeamActive(1, false))
+	assert.Empty(t, f.TeamIDs())
+	assert.Equal(t, []Actor{NewActor(KindTeam, "acme")}, f.Actors())
+}
+
+func TestRolloutActorPercentage(t *testing.T) {
+	f := NewFeature("example")
+
+	f.activateActorPercentage(KindUser, 50)
+	assert.EqualValues(t, 50, f.ActorPercentage(KindUser))
+	assert.Equal(t, []ActorKind{KindUser}, f.ActorKinds())
+	assert.Zero(t, f.Percentage())
+
+	// the same ids are bucketed the same way as teams
+	assert.True(t, f.evaluate(evaluation{actor: NewIntActor(KindUser, 10), randomizePercentage: true}))
+	assert.False(t, f.evaluate(evaluation{actor: NewIntActor(KindUser, 10)}))
+	assert.False(t, f.isTeamActive(10, true))
+	assert.False(t, f.evaluate(evaluation{actor: NewIntActor(KindAccount, 10), randomizePercentage: true}))
+
+	// the percentage of teams is the percentage of the feature
+	f.activateActorPercentage(KindTeam, 50)
+	assert.EqualValues(t, 50, f.Percentage())
+	assert.True(t, f.isTeamActive(10, true))
+
+	f.activateActorPercentage(KindUser, 0)
+	assert.Empty(t, f.ActorKinds())
+	assert.False(t, f.evaluate(evaluation{actor: NewIntActor(KindUser, 10), randomizePercentage: true}))
+
+	// globally active features are active for every actor
+	f.activate()
+	assert.True(t, f.evaluate(evaluation{actor: NewActor(KindAccount, "abc")}))
+}
+
+func TestEncodeDecodeActors(t *testing.T) {
+	in := NewFeature("example")
+	in.activateTeam(1)
+	in.activateActor(NewActor(KindUser, "abc"))
+	in.activateActorPercentage(KindAccount, 25)
+
+	data, err := msgpack.Marshal(in)
+	assert.NoError(t, err)
+
+	out := NewFeature("example")
+	assert.NoError(t, msgpack.Unmarshal(data, out))
+	assert.Equal(t, in.teamIDs, out.teamIDs)
+	assert.Equal(t, in.actors, out.actors)
+	assert.Equal(t, in.actorPercentages, out.actorPercentages)
+
+	// decoding drops the actors of the previous state
+	data, err = msgpack.Marshal(NewFeature("example"))
+	assert.NoError(t, err)
+
+	assert.NoError(t, msgpack.Unmarshal(data, out))
+	assert.Empty(t, out.actors)
+	assert.Empty(t, out.actorPercentages)
+}
+
+func TestSegmentFeature(t *testing.T) {
+	f := NewFeature("example")
+	f.activateSegment("beta")
+	f.activateSegment("internal")
+	assert.Equal(t, []string{"beta", "internal"}, f.Segments())
+
+	beta := newSegment("beta")
+	beta.addTeams(1)
+	segments := map[string]*Segment{"beta": beta}
+
+	assert.True(t, f.evaluate(evaluation{actor: Team(1), segments: segments}))
+	assert.False(t, f.evaluate(evaluation{actor: Team(2), segments: segments}))
+	assert.False(t, f.evaluate(evaluation{actor: NewIntActor(KindUser, 1), segments: segments}))
+	assert.False(t, f.isTeamActive(1, false))
+
+	data, err := msgpack.Marshal(f)
+	assert.NoError(t, err)
+
+	out := NewFeature("example")
+	assert.NoError(t, msgpack.Unmarshal(data, out))
+	assert.Equal(t, f.segments, out.segments)
+
+	f.deactivateSegment("beta")
+	assert.False(t, f.evaluate(evaluation{actor: Team(1), segments: segments}))
+}
+
+func TestFeatureBlockTeam(t *testing.T) {
+	f := NewFeature("example")
+	f.activate()
+	f.activateTeam(1)
+	f.activateSegment("beta")
+	f.blockTeam(1)
+	f.blockTeam(2)
+	assert.Equal(t, []int64{1, 2}, f.BlockedTeamIDs())
+
+	beta := newSegment("beta")
+	beta.addTeams(2)
+	segments := map[string]*Segment{"beta": beta}
+
+	// blocked teams override the global, explicit and segment activation
+	assert.False(t, f.isTeamActive(1, false))
+	assert.False(t, f.evaluate(evaluation{actor: Team(2), segments: segments}))
+	assert.True(t, f.isTeamActive(3, false))
+
+	// and the percentage
+	f.activatePercentage(50)
+	f.blockTeam(10)
+	assert.False(t, f.isTeamActive(10, true))
+
+	f.unblockTeam(1)
+	f.activateTeam(1)
+	assert.True(t, f.isTeamActive(1, false))
+}
+
+func TestBasisPoints(t *testing.T) {
+	// whole percentages bucket the same teams as before basis points were supported
+	for percentage := uint32(0); percentage <= 100; percentage++ {
+		assert.Equal(t, randBase*percentage, bucketThreshold(uint16(percentage*100)))
+	}
+
+	f := NewFeature("example")
+	f.activateBasisPoints(2550)
+	assert.EqualValues(t, 25, f.Percentage())
+	assert.EqualValues(t, 2550, f.BasisPoints())
+	assert.False(t, f.isActive())
+
+	// a fraction of a percent activates fewer teams than a whole percent
+	f.activateBasisPoints(5)
+	var fraction, whole int
+	for teamID := int64(0); teamID < 100000; teamID++ {
+		if f.isTeamActive(teamID, true) {
+			fraction++
+		}
+	}
+	f.activatePercentage(1)
+	assert.EqualValues(t, 100, f.BasisPoints())
+	for teamID := int64(0); teamID < 100000; teamID++ {
+		if f.isTeamActive(teamID, true) {
+			whole++
+		}
+	}
+	assert.InDelta(t, 50, fraction, 25)
+	assert.InDelta(t, 1000, whole, 100)
+
+	f.activateBasisPoints(MaxBasisPoints + 1)
+	assert.True(t, f.isActive())
+
+	// persisted after the whole percentage, which older readers understand
+	f.activateBasisPoints(1005)
+	data, err := msgpack.Marshal(f)
+	assert.NoError(t, err)
+
+	out := NewFeature("example")
+	assert.NoError(t, msgpack.Unmarshal(data, out))
+	assert.EqualValues(t, 1005, out.BasisPoints())
+
+	var percentage uint8
+	assert.NoError(t, msgpack.Unmarshal(data, &percentage))
+	assert.EqualValues(t, 10, percentage)
+
+	// and alongside it in map records
+	data, err = msgpack.Marshal(versionedRecord{f})
+	assert.NoError(t, err)
+
+	var record map[string]interface{}
+	assert.NoError(t, msgpack.Unmarshal(data, &record))
+	assert.EqualValues(t, 10, record["percentage"])
+	assert.EqualValues(t, 5, record["fraction"])
+}
+
+func TestSaltAndHash(t *testing.T) {
+	a := NewFeature("a")
+	b := NewFeature("b")
+	a.activatePercentage(50)
+	b.activatePercentage(50)
+	assert.Equal(t, HashCRC32, a.Hash())
+
+	// features sharing a salt are rolled out to the same teams, regardless of randomizing
+	a.salt = "cohort"
+	b.salt = "cohort"
+	var same, differentHash int
+	for teamID := int64(0); teamID < 1000; teamID++ {
+		if a.isTeamActive(teamID, true) == b.isTeamActive(teamID, false) {
+			same++
+		}
+	}
+	assert.Equal(t, 1000, same)
+
+	b.hash = HashMurmur3
+	for teamID := int64(0); teamID < 1000; teamID++ {
+		if a.isTeamActive(teamID, true) != b.isTeamActive(teamID, true) {
+			differentHash++
+		}
+	}
+	assert.InDelta(t, 500, differentHash, 100)
+}
+
+func TestFeatureRules(t *testing.T) {
+	plan, _ := ParseClause("plan eq enterprise")
+	country, _ := ParseClause("country in US,CA")
+
+	f := NewFeature("example")
+	f.addRule(Rule{plan})
+	f.addRule(Rule{country})
+	assert.Len(t, f.Rules(), 2)
+
+	assert.True(t, f.evaluate(evaluation{actor: Team(1), attributes: Attributes{"plan": "enterprise"}}))
+	assert.True(t, f.evaluate(evaluation{actor: NewActor(KindUser, "abc"), attributes: Attributes{"country": "CA"}}))
+	assert.False(t, f.evaluate(evaluation{actor: Team(1), attributes: Attributes{"plan": "free", "country": "MX"}}))
+	assert.False(t, f.isTeamActive(1, false))
+
+	// blocked teams override the rules
+	f.blockTeam(1)
+	assert.False(t, f.evaluate(evaluation{actor: Team(1), attributes: Attributes{"plan": "enterprise"}}))
+
+	data, err := msgpack.Marshal(f)
+	assert.NoError(t, err)
+
+	out := NewFeature("example")
+	assert.NoError(t, msgpack.Unmarshal(data, out))
+	assert.Equal(t, f.rules, out.rules)
+
+	assert.True(t, f.removeRule(Rule{plan}))
+	assert.False(t, f.removeRule(Rule{plan}))
+	assert.Equal(t, []Rule{{country}}, f.Rules())
+
+	f.deactivate()
+	assert.Empty(t, f.Rules())
+}
+
+func TestFeatureFields(t *testing.T) {
+	now := time.Unix(1577836800, 0)
+	plan, _ := ParseClause("plan eq enterprise")
+
+	for _, tc := range []struct {
+		name string
+		set  func(f *Feature)
+		get  func(f *Feature) interface{}
+		kept bool // whether the field is kept when the feature is deactivated
+	}{
+		{"percentage", func(f *Feature) { f.activateBasisPoints(1005) }, func(f *Feature) interface{} { return f.BasisPoints() }, false},
+		{"teams", func(f *Feature) { f.activateTeam(1) }, func(f *Feature) interface{} { return f.TeamIDs() }, false},
+		{"actors", func(f *Feature) { f.activateActor(NewActor(KindUser, "abc")) }, func(f *Feature) interface{} { return f.Actors() }, false},
+		{"actor percentages", func(f *Feature) { f.activateActorPercentage(KindAccount, 5) }, func(f *Feature) interface{} { return f.ActorPercentage(KindAccount) }, false},
+		{"segments", func(f *Feature) { f.activateSegment("beta") }, func(f *Feature) interface{} { return f.Segments() }, false},
+		{"rules", func(f *Feature) { f.addRule(Rule{plan}) }, func(f *Feature) interface{} { return f.Rules() }, false},
+		{"ramp", func(f *Feature) { f.ramp = &Ramp{Steps: []RampStep{{100, time.Hour}}, StartedAt: now} }, func(f *Feature) interface{} { return f.ramp }, false},
+		{"blocked teams", func(f *Feature) { f.blockTeam(1) }, func(f *Feature) interface{} { return f.BlockedTeamIDs() }, true},
+		{"salt", func(f *Feature) { f.salt = "cohort" }, func(f *Feature) interface{} { return f.Salt() }, true},
+		{"hash", func(f *Feature) { f.hash = HashMurmur3 }, func(f *Feature) interface{} { return f.Hash() }, true},
+		{"variants", func(f *Feature) { f.setVariant("blue", 1) }, func(f *Feature) interface{} { return f.Variants() }, true},
+		{"payloads", func(f *Feature) { f.setPayload("", NewNumberPayload(10)) }, func(f *Feature) interface{} { return f.Payloads() }, true},
+		{"schedule", func(f *Feature) { f.scheduleChange(ScheduledChange{At: now, Operation: ScheduleActivate}) }, func(f *Feature) interface{} { return f.Schedule() }, true},
+		{"prerequisites", func(f *Feature) { f.addPrerequisite("other") }, func(f *Feature) interface{} { return f.Prerequisites() }, true},
+		{"metadata", func(f *Feature) {
+			f.metadata.Description = "New checkout flow"
+			f.metadata.Owner = "payments"
+			f.addTags("checkout")
+			f.touch("alice", now)
+		}, func(f *Feature) interface{} { return f.Metadata() }, true},
+		{"version", func(f *Feature) { f.version = 3 }, func(f *Feature) interface{} { return f.Version() }, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			blank := tc.get(NewFeature("example"))
+
+			f := NewFeature("example")
+			tc.set(f)
+			want := tc.get(f)
+			assert.NotEqual(t, blank, want)
+
+			// as legacy and map records
+			for _, record := range []interface{}{f, versionedRecord{f}} {
+				data, err := msgpack.Marshal(record)
+				assert.NoError(t, err)
+
+				out := NewFeature("example")
+				assert.NoError(t, msgpack.Unmarshal(data, out))
+				assert.Equal(t, want, tc.get(out))
+			}
+
+			// kept or cleared when deactivated, and always cleared when reset
+			f.deactivate()
+			if tc.kept {
+				assert.Equal(t, want, tc.get(f))
+			} else {
+				assert.Equal(t, blank, tc.get(f))
+			}
+
+			f.reset()
+			assert.Equal(t, blank, tc.get(f))
+		})
+	}
+}
@@ -3,22 +3,33 @@ module github.com/salesloft/gorollout
 go 1.21
 
 require (
+	github.com/alicebob/miniredis/v2 v2.33.0
+	github.com/cespare/xxhash/v2 v2.3.0
 	github.com/go-redis/redis/v7 v7.4.1
+	github.com/go-redis/redis/v8 v8.11.5
+	github.com/redis/go-redis/v9 v9.7.3
+	github.com/redis/rueidis v1.0.53
 	github.com/stretchr/testify v1.7.1
+	github.com/twmb/murmur3 v1.1.8
 	github.com/urfave/cli/v2 v2.8.1
 	github.com/vmihailenco/msgpack/v4 v4.3.12
+	golang.org/x/mod v0.17.0
 )
 
 require (
+	github.com/alicebob/gopher-json v0.0.0-20200520072559-a9ecdc9d1d3a // indirect
 	github.com/cpuguy83/go-md2man/v2 v2.0.2 // indirect
 	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
 	github.com/golang/protobuf v1.5.2 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/russross/blackfriday/v2 v2.1.0 // indirect
 	github.com/vmihailenco/tagparser v0.1.2 // indirect
 	github.com/xrash/smetrics v0.0.0-20201216005158-039620a65673 // indirect
-	golang.org/x/net v0.0.0-20220524220425-1d687d428aca // indirect
+	github.com/yuin/gopher-lua v1.1.1 // indirect
+	golang.org/x/net v0.27.0 // indirect
+	golang.org/x/sys v0.24.0 // indirect
 	google.golang.org/appengine v1.6.7 // indirect
 	google.golang.org/protobuf v1.28.0 // indirect
-	gopkg.in/yaml.v3 v3.0.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -0,0 +1,46 @@
+package rollout
+
+import (
+	"fmt"
+	"hash/crc32"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/twmb/murmur3"
+)
+
+// Hash names the algorithm actors are bucketed with for percentage rollouts
+type Hash string
+
+const (
+	// HashCRC32 buckets actors by their CRC32 checksum, the default which features have always been bucketed with
+	HashCRC32 Hash = "crc32"
+
+	// HashMurmur3 buckets actors by their 32-bit murmur3 hash
+	HashMurmur3 Hash = "murmur3"
+
+	// HashXXHash buckets actors by the upper 32 bits of their xxhash
+	HashXXHash Hash = "xxhash"
+)
+
+// validate returns an error when the hash isn't known, the empty hash being the default
+func (h Hash) validate() error {
+	switch h {
+	case "", HashCRC32, HashMurmur3, HashXXHash:
+		return nil
+	default:
+		return fmt.Errorf("unknown hash %q", string(h))
+	}
+}
+
+// sum hashes the data into the space percentage rollouts are bucketed in
+func (h Hash) sum(data []byte) uint32 {
+	switch h {
+	case HashMurmur3:
+		return murmur3.Sum32(data)
+	case HashXXHash:
+		return uint32(xxhash.Sum64(data) >> 32)
+	default:
+		// unknown hashes written by newer versions fall back to the default
+		return crc32.ChecksumIEEE(data)
+	}
+}
@@ -0,0 +1,35 @@
+package rollout
+
+import (
+	"hash/crc32"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHash(t *testing.T) {
+	assert.NoError(t, Hash("").validate())
+	assert.NoError(t, HashCRC32.validate())
+	assert.NoError(t, HashMurmur3.validate())
+	assert.NoError(t, HashXXHash.validate())
+	assert.EqualError(t, Hash("md5").validate(), `unknown hash "md5"`)
+
+	data := []byte("example1")
+	assert.Equal(t, crc32.ChecksumIEEE(data), Hash("").sum(data))
+	assert.Equal(t, crc32.ChecksumIEEE(data), HashCRC32.sum(data))
+	assert.Equal(t, crc32.ChecksumIEEE(data), Hash("md5").sum(data))
+	assert.NotEqual(t, crc32.ChecksumIEEE(data), HashMurmur3.sum(data))
+	assert.NotEqual(t, crc32.ChecksumIEEE(data), HashXXHash.sum(data))
+
+	// every hash spreads sequential ids evenly
+	for _, hash := range []Hash{HashCRC32, HashMurmur3, HashXXHash} {
+		var bucketed int
+		for id := 0; id < 10000; id++ {
+			if hash.sum([]byte(strconv.Itoa(id))) < bucketThreshold(2500) {
+				bucketed++
+			}
+		}
+		assert.InDelta(t, 2500, bucketed, 250, string(hash))
+	}
+}
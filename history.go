@@ -0,0 +1,263 @@
+package rollout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v4"
+)
+
+const (
+	// DefaultHistoryLimit is the number of changes kept in the history of each feature, unless set with WithHistoryLimit
+	DefaultHistoryLimit = 100
+)
+
+// SegmentHistoryEntry records a change made to a segment, and by whom
+type SegmentHistoryEntry struct {
+	At        time.Time
+	Author    string   // empty when unknown
+	Operation string   // describes the change, e.g. "add-teams 1 2"
+	Reason    string   // why the change was made, empty when not given
+	Before    *Segment // the segment before the change, nil when it wasn't persisted
+	After     *Segment // the segment after the change, nil when it was deleted
+}
+
+// HistoryEntry records a change made to a feature, and by whom
+type HistoryEntry struct {
+	Version   int64 // the version of the feature the change made
+	At        time.Time
+	Author    string   // empty when unknown
+	Operation string   // describes the change, e.g. "activate-team 42"
+	Reason    string   // why the change was made, empty when not given
+	Before    *Feature // the state of the feature before the change, nil when it wasn't persisted
+	After     *Feature // the state of the feature after the change, nil when it was deleted
+}
+
+// HistoryError is returned when a change was made to a feature or segment, but couldn't be recorded in its history
+type HistoryError struct {
+	Feature string // the name of the changed feature, empty when the change was made to a segment
+	Segment string // the name of the changed segment, empty when the change was made to a feature
+	Err     error  // why the change couldn't be recorded
+}
+
+func (e *HistoryError) Error() string {
+	if e.Segment != "" {
+		return fmt.Sprintf("segment %q was changed, but the change couldn't be recorded in its history: %v", e.Segment, e.Err)
+	}
+
+	return fmt.Sprintf("feature %q was changed, but the change couldn't be recorded in its history: %v", e.Feature, e.Err)
+}
+
+// Unwrap returns why the change couldn't be recorded
+func (e *HistoryError) Unwrap() error {
+	return e.Err
+}
+
+// historyRecord is a history entry as it's stored, with the states of the feature or segment encoded
+type historyRecord struct {
+	Version   int64
+	At        time.Time
+	Author    string
+	Operation string
+	Reason    string
+	Before    []byte
+	After     []byte
+}
+
+// EncodeMsgpack implements msgpack.CustomEncoder
+func (r historyRecord) EncodeMsgpack(enc *msgpack.Encoder) error {
+	return encodeTuple(enc, r.Version, unixNano(r.At), r.Author, r.Operation, r.Reason, r.Before, r.After)
+}
+
+// DecodeMsgpack implements msgpack.CustomDecoder
+func (r *historyRecord) DecodeMsgpack(dec *msgpack.Decoder) error {
+	var at int64
+	if err := decodeTuple(dec, &r.Version, &at, &r.Author, &r.Operation, &r.Reason, &r.Before, &r.After); err != nil {
+		return err
+	}
+	r.At = fromUnixNano(at)
+
+	return nil
+}
+
+type reasonKey struct{}
+
+// ContextWithReason returns a copy of the context recording why the changes made with it are made, in the history of the changed features
+func ContextWithReason(ctx context.Context, reason string) context.Context {
+	return context.WithValue(ctx, reasonKey{}, reason)
+}
+
+// reasonFromContext returns why the changes made with the context are made, empty when not given
+func reasonFromContext(ctx context.Context) string {
+	reason, _ := ctx.Value(reasonKey{}).(string)
+	return reason
+}
+
+// operation describes a change made to a feature by its name and arguments, e.g. "activate-team 42"
+func operation(name string, args ...interface{}) string {
+	return strings.TrimSpace(fmt.Sprintln(append([]interface{}{name}, args...)...))
+}
+
+// historyKeyName is the key of the history of the feature, which doesn't overlap with the feature keys
+func (m *Manager) historyKeyName(feature *Feature) string {
+	return m.keyPrefix + "-history:" + feature.Name()
+}
+
+// segmentHistoryKeyName is the key of the history of the segment, which doesn't overlap with the segment keys
+func (m *Manager) segmentHistoryKeyName(name string) string {
+	return m.keyPrefix + "-segment-history:" + name
+}
+
+// record appends the change to the history kept under the key, when the store keeps it
+func (m *Manager) record(ctx context.Context, key string, entry historyRecord) error {
+	if m.journal == nil {
+		return nil
+	}
+
+	data, err := msgpack.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	return m.journal.Append(ctx, key, data, m.historyLimit)
+}
+
+// historyError wraps the error recording a change made to the feature, nil when it was recorded
+func (m *Manager) historyError(feature *Feature, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &HistoryError{Feature: feature.Name(), Err: err}
+}
+
+// segmentHistoryError wraps the error recording a change made to the segment, nil when it was recorded
+func (m *Manager) segmentHistoryError(name string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &HistoryError{Segment: name, Err: err}
+}
+
+// lastVersion returns the version of the most recent change in the history of the feature, 0 when there's none
+// or it can't be read, which mustn't keep the feature from being written
+func (m *Manager) lastVersion(ctx context.Context, feature *Feature) int64 {
+	if m.journal == nil {
+		return 0
+	}
+
+	entries, err := m.journal.Entries(ctx, m.historyKeyName(feature), 1)
+	if err != nil || len(entries) == 0 {
+		return 0
+	}
+
+	var entry historyRecord
+	if err := msgpack.Unmarshal(entries[0], &entry); err != nil {
+		return 0
+	}
+
+	return entry.Version
+}
+
+// History returns the most recent changes made to the feature, oldest first
+func (m *Manager) History(feature *Feature) ([]HistoryEntry, error) {
+	return m.HistoryContext(context.Background(), feature)
+}
+
+// HistoryContext returns the most recent changes made to the feature, oldest first, bounded by the context
+func (m *Manager) HistoryContext(ctx context.Context, feature *Feature) ([]HistoryEntry, error) {
+	records, err := m.records(ctx, m.historyKeyName(feature))
+	if err != nil {
+		return nil, err
+	}
+
+	history := make([]HistoryEntry, 0, len(records))
+	for _, record := range records {
+		entry := HistoryEntry{
+			Version:   record.Version,
+			At:        record.At,
+			Author:    record.Author,
+			Operation: record.Operation,
+			Reason:    record.Reason,
+		}
+		if record.Before != nil {
+			if entry.Before, err = m.decode(feature, record.Before); err != nil {
+				return nil, err
+			}
+		}
+		if record.After != nil {
+			if entry.After, err = m.decode(feature, record.After); err != nil {
+				return nil, err
+			}
+		}
+
+		history = append(history, entry)
+	}
+
+	return history, nil
+}
+
+// SegmentHistory returns the most recent changes made to the segment, oldest first
+func (m *Manager) SegmentHistory(name string) ([]SegmentHistoryEntry, error) {
+	return m.SegmentHistoryContext(context.Background(), name)
+}
+
+// SegmentHistoryContext returns the most recent changes made to the segment, oldest first, bounded by the context
+func (m *Manager) SegmentHistoryContext(ctx context.Context, name string) ([]SegmentHistoryEntry, error) {
+	records, err := m.records(ctx, m.segmentHistoryKeyName(name))
+	if err != nil {
+		return nil, err
+	}
+
+	history := make([]SegmentHistoryEntry, 0, len(records))
+	for _, record := range records {
+		entry := SegmentHistoryEntry{
+			At:        record.At,
+			Author:    record.Author,
+			Operation: record.Operation,
+			Reason:    record.Reason,
+		}
+		if record.Before != nil {
+			if entry.Before, err = m.decodeSegment(name, record.Before); err != nil {
+				return nil, err
+			}
+		}
+		if record.After != nil {
+			if entry.After, err = m.decodeSegment(name, record.After); err != nil {
+				return nil, err
+			}
+		}
+
+		history = append(history, entry)
+	}
+
+	return history, nil
+}
+
+// records returns the most recent changes in the history kept under the key, oldest first
+func (m *Manager) records(ctx context.Context, key string) ([]historyRecord, error) {
+	if m.historyLimit <= 0 {
+		return nil, errors.New("history of changes isn't recorded")
+	}
+	if m.journal == nil {
+		return nil, errors.New("store doesn't keep the history of changes")
+	}
+
+	entries, err := m.journal.Entries(ctx, key, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]historyRecord, len(entries))
+	for i, data := range entries {
+		if err := msgpack.Unmarshal(data, &records[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	return records, nil
+}
@@ -0,0 +1,157 @@
+package rollout
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOperation(t *testing.T) {
+	assert.Equal(t, "activate", operation("activate"))
+	assert.Equal(t, "activate-team 42", operation("activate-team", int64(42)))
+	assert.Equal(t, "activate-actor user:abc", operation("activate-actor", NewActor(KindUser, "abc")))
+	assert.Equal(t, "set-salt", operation("set-salt", ""))
+	assert.Equal(t, "activate-percentage 0.05", operation("activate-percentage", float64(5)/100))
+}
+
+func TestManagerHistory(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	manager := NewManagerWithStore(NewMemoryStore(), mockKeyPrefix, true, WithHistory(DefaultHistoryLimit))
+	manager.now = func() time.Time { return now }
+
+	f := NewFeature("example")
+	ctx := ContextWithReason(ContextWithAuthor(context.Background(), "alice"), "beta launch")
+	assert.NoError(t, manager.ActivateTeamContext(ctx, 42, f))
+	now = now.Add(time.Hour)
+	assert.NoError(t, manager.ActivatePercentage(f, 25))
+	assert.EqualValues(t, 2, f.Version())
+
+	history, err := manager.History(f)
+	assert.NoError(t, err)
+	assert.Len(t, history, 2)
+
+	entry := history[0]
+	assert.EqualValues(t, 1, entry.Version)
+	assert.True(t, entry.At.Equal(now.Add(-time.Hour)))
+	assert.Equal(t, "alice", entry.Author)
+	assert.Equal(t, "activate-team 42", entry.Operation)
+	assert.Equal(t, "beta launch", entry.Reason)
+	assert.Nil(t, entry.Before)
+	assert.Equal(t, []int64{42}, entry.After.TeamIDs())
+
+	entry = history[1]
+	assert.EqualValues(t, 2, entry.Version)
+	assert.Empty(t, entry.Author)
+	assert.Equal(t, "activate-percentage 25", entry.Operation)
+	assert.Empty(t, entry.Reason)
+	assert.EqualValues(t, 0, entry.Before.Percentage())
+	assert.EqualValues(t, 25, entry.After.Percentage())
+
+	// deletions are recorded, and versions carry on when the feature is changed again
+	deleted, err := manager.DeleteContext(ctx, f)
+	assert.NoError(t, err)
+	assert.True(t, deleted)
+	assert.NoError(t, manager.Activate(f))
+	assert.EqualValues(t, 4, f.Version())
+
+	history, err = manager.History(f)
+	assert.NoError(t, err)
+	assert.Len(t, history, 4)
+	assert.EqualValues(t, 3, history[2].Version)
+	assert.Equal(t, "delete", history[2].Operation)
+	assert.EqualValues(t, 25, history[2].Before.Percentage())
+	assert.Nil(t, history[2].After)
+	assert.Nil(t, history[3].Before)
+	assert.True(t, history[3].After.isActive())
+}
+
+func TestManagerHistoryLimit(t *testing.T) {
+	manager := NewManagerWithStore(NewMemoryStore(), mockKeyPrefix, true, WithHistory(2))
+
+	f := NewFeature("example")
+	for teamID := int64(1); teamID <= 3; teamID++ {
+		assert.NoError(t, manager.ActivateTeam(teamID, f))
+	}
+
+	// only the most recent changes are kept
+	history, err := manager.History(f)
+	assert.NoError(t, err)
+	assert.Len(t, history, 2)
+	assert.Equal(t, "activate-team 2", history[0].Operation)
+	assert.Equal(t, "activate-team 3", history[1].Operation)
+
+	// not recorded unless enabled
+	manager = NewManagerWithStore(NewMemoryStore(), mockKeyPrefix, true)
+	assert.NoError(t, manager.ActivateTeam(1, f))
+	_, err = manager.History(f)
+	assert.EqualError(t, err, "history of changes isn't recorded")
+
+	manager = NewManagerWithStore(NewMemoryStore(), mockKeyPrefix, true, WithHistory(0))
+	_, err = manager.History(f)
+	assert.EqualError(t, err, "history of changes isn't recorded")
+}
+
+func TestManagerHistoryRedis(t *testing.T) {
+	client := &MockClient{}
+	manager := NewManager(client, mockKeyPrefix, false, WithHistory(DefaultHistoryLimit))
+
+	f := NewFeature("example")
+	assert.NoError(t, manager.ActivateTeam(1, f))
+	assert.NoError(t, manager.Activate(f))
+
+	history, err := manager.History(f)
+	assert.NoError(t, err)
+	assert.Len(t, history, 2)
+	assert.Equal(t, "activate-team 1", history[0].Operation)
+	assert.Equal(t, "activate", history[1].Operation)
+	assert.True(t, history[1].After.isActive())
+	assert.Contains(t, client.journal, mockKeyPrefix+"-history:example")
+}
+
+// failingJournalStore is a MemoryStore failing to read and append to its logs, as redis before 5 does
+type failingJournalStore struct {
+	*MemoryStore
+}
+
+func (s failingJournalStore) Append(ctx context.Context, key string, entry []byte, max int) error {
+	return errors.New("unknown command 'xadd'")
+}
+
+func (s failingJournalStore) Entries(ctx context.Context, key string, count int) ([][]byte, error) {
+	return nil, errors.New("unknown command 'xrevrange'")
+}
+
+func TestManagerHistoryError(t *testing.T) {
+	store := failingJournalStore{NewMemoryStore()}
+	manager := NewManagerWithStore(store, mockKeyPrefix, true, WithCache(time.Hour, time.Hour), WithHistory(DefaultHistoryLimit))
+	other := NewManagerWithStore(store, mockKeyPrefix, true, WithCache(time.Hour, time.Hour))
+	assert.NoError(t, other.Subscribe(context.Background()))
+
+	// the feature is created, cached and announced even though the change isn't recorded
+	f := NewFeature("example")
+	active, err := other.IsTeamActive(1, f)
+	assert.NoError(t, err)
+	assert.False(t, active)
+
+	err = manager.ActivateTeam(1, f)
+	assert.EqualError(t, err, `feature "example" was changed, but the change couldn't be recorded in its history: unknown command 'xadd'`)
+	var historyErr *HistoryError
+	assert.True(t, errors.As(err, &historyErr))
+	assert.Equal(t, "example", historyErr.Feature)
+
+	active, err = manager.IsTeamActive(1, f)
+	assert.NoError(t, err)
+	assert.True(t, active)
+
+	assert.Eventually(t, func() bool {
+		active, err := other.IsTeamActive(1, f)
+		return err == nil && active
+	}, time.Second, time.Millisecond)
+
+	deleted, err := manager.Delete(f)
+	assert.True(t, deleted)
+	assert.IsType(t, &HistoryError{}, err)
+}
@@ -1,292 +1,942 @@
 package rollout
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	redis "github.com/go-redis/redis/v7"
-	"github.com/vmihailenco/msgpack/v4"
 )
 
-// Manager persists and fetches feature toggles to/from redis
+const (
+	// maxUpdateAttempts is the number of times a change is retried when the feature is concurrently modified
+	maxUpdateAttempts = 10
+)
+
+// ConflictError is returned when a change could not be applied because the feature or segment
+// kept being modified concurrently until the retries were exhausted
+type ConflictError struct {
+	Feature  string // the name of the feature, empty when the change was made to a segment
+	Segment  string // the name of the segment, empty when the change was made to a feature
+	Attempts int    // the number of attempts made to apply the change
+}
+
+func (e *ConflictError) Error() string {
+	if e.Segment != "" {
+		return fmt.Sprintf("segment %q was modified concurrently, gave up after %d attempts", e.Segment, e.Attempts)
+	}
+
+	return fmt.Sprintf("feature %q was modified concurrently, gave up after %d attempts", e.Feature, e.Attempts)
+}
+
+// NotifyError is returned when a change was made to a feature or segment, but couldn't be announced
+// to the other Managers, which keep evaluating their cached state until it expires
+type NotifyError struct {
+	Feature string // the name of the changed feature, empty when the change was made to a segment
+	Segment string // the name of the changed segment, empty when the change was made to a feature
+	Err     error  // why the change couldn't be announced
+}
+
+func (e *NotifyError) Error() string {
+	if e.Segment != "" {
+		return fmt.Sprintf("segment %q was changed, but the change couldn't be announced: %v", e.Segment, e.Err)
+	}
+
+	return fmt.Sprintf("feature %q was changed, but the change couldn't be announced: %v", e.Feature, e.Err)
+}
+
+// Unwrap returns why the change couldn't be announced
+func (e *NotifyError) Unwrap() error {
+	return e.Err
+}
+
+// Manager persists and fetches feature toggles to/from a store
 type Manager struct {
-	client              redis.Cmdable
+	store               Store
+	notifier            Notifier // the store's change notifications, nil when unsupported
+	journal             Journal  // the store's logs recording the history of changes, nil when unsupported or disabled
+	historyLimit        int      // the number of changes kept in the history of each feature, 0 when not recorded
+	versionedRecords    bool     // whether features are written as map records, rather than legacy ones
 	keyPrefix           string
 	randomizePercentage bool
+	cache               *cache           // features cached in memory, nil when caching is disabled
+	now                 func() time.Time // the current time, overridable for testing
+	fallback            Fallback         // what evaluations return when the store can't be reached
+	breaker             *breaker         // guards the store, nil when disabled
+	lastKnown           sync.Map         // the last state fetched for each feature key, when falling back to it
+
+	snapshot   atomic.Pointer[snapshot] // all features fetched from the store, nil when not polling
+	refreshMu  sync.Mutex
+	refreshErr error // the error of the most recent snapshot refresh
 }
 
-// NewManager constructs a new Manager instance
-func NewManager(client redis.Cmdable, keyPrefix string, randomizePercentage bool) *Manager {
-	// nothing is retrieved from redis at this point
+// NewManager constructs a new Manager instance backed by redis
+func NewManager(client redis.Cmdable, keyPrefix string, randomizePercentage bool, opts ...Option) *Manager {
+	return NewManagerWithStore(NewRedisStore(client), keyPrefix, randomizePercentage, opts...)
+}
+
+// NewManagerWithStore constructs a new Manager instance backed by the given store
+func NewManagerWithStore(store Store, keyPrefix string, randomizePercentage bool, opts ...Option) *Manager {
+	// nothing is retrieved from the store at this point
 	// everything is fetched on demand
-	return &Manager{
-		client:              client,
+	m := &Manager{
+		store:               store,
 		keyPrefix:           keyPrefix,
 		randomizePercentage: randomizePercentage,
+		now:                 time.Now,
+	}
+
+	m.notifier, _ = store.(Notifier)
+	m.journal, _ = store.(Journal)
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	if m.historyLimit <= 0 {
+		m.journal = nil
+	}
+
+	if m.breaker != nil {
+		m.store = &breakerStore{store: store, breaker: m.breaker}
 	}
+
+	return m
 }
 
 func (m *Manager) keyName(feature *Feature) string {
 	return m.keyPrefix + ":" + feature.Name()
 }
 
-// get updates the Feature to align with the current value in redis
-func (m *Manager) get(feature *Feature) error {
-	// retrieve feature from redis
-	data, err := m.client.Get(m.keyName(feature)).Bytes()
+// channelName is the pub/sub channel announcing the names of changed features
+func (m *Manager) channelName() string {
+	return m.keyPrefix + ":changes"
+}
+
+// decode returns a copy of the feature aligned with the given data
+func (m *Manager) decode(feature *Feature, data []byte) (*Feature, error) {
+	state := feature.blank()
+	if err := state.load(data); err != nil {
+		return nil, err
+	}
+
+	return state, nil
+}
+
+// load returns the current state of the feature, with the scheduled changes which are due made and the rollout
+// its ramp is at, which must not be modified as it may be shared with other callers
+func (m *Manager) load(ctx context.Context, feature *Feature) (*Feature, error) {
+	state, err := m.stored(ctx, feature)
 	if err != nil {
-		if err == redis.Nil {
-			// feature isn't in redis, so should be inactive
-			feature.Lock()
-			feature.deactivate()
-			feature.Unlock()
-			return nil
+		return nil, err
+	}
+
+	return state.at(m.now()), nil
+}
+
+// loadMulti returns the current states of the features, with the scheduled changes which are due made and the rollout their ramps are at
+func (m *Manager) loadMulti(ctx context.Context, features ...*Feature) ([]*Feature, error) {
+	states, err := m.storedMulti(ctx, features...)
+	if err != nil {
+		return nil, err
+	}
+
+	now := m.now()
+	for i, state := range states {
+		states[i] = state.at(now)
+	}
+
+	return states, nil
+}
+
+// stored returns the state of the feature as it's stored, from the cache when enabled
+func (m *Manager) stored(ctx context.Context, feature *Feature) (*Feature, error) {
+	if state, ok := m.cached(feature); ok {
+		return state, nil
+	}
+
+	// retrieve feature from the store
+	fetchedAt := m.now()
+	data, err := m.store.Get(ctx, m.keyName(feature))
+	if err != nil {
+		return m.fallbackState(feature, err)
+	}
+
+	return m.fetched(feature, data, fetchedAt)
+}
+
+// storedMulti returns the states of the features as they're stored, only fetching the ones that aren't cached from the store
+func (m *Manager) storedMulti(ctx context.Context, features ...*Feature) ([]*Feature, error) {
+	states := make([]*Feature, len(features))
+
+	var missing []int
+	var keys []string
+	for i, feature := range features {
+		if state, ok := m.cached(feature); ok {
+			states[i] = state
+			continue
 		}
-		return err
+
+		missing = append(missing, i)
+		keys = append(keys, m.keyName(feature))
 	}
 
-	feature.Lock()
-	defer feature.Unlock()
-	if err := msgpack.Unmarshal(data, feature); err != nil {
-		return err
+	if len(keys) == 0 {
+		return states, nil
 	}
 
-	return nil
+	// retrieve features from the store
+	fetchedAt := m.now()
+	val, err := m.store.MGet(ctx, keys...)
+	if err != nil {
+		for _, i := range missing {
+			if states[i], err = m.fallbackState(features[i], err); err != nil {
+				return nil, err
+			}
+		}
+
+		return states, nil
+	}
+
+	for j, i := range missing {
+		if states[i], err = m.fetched(features[i], val[j], fetchedAt); err != nil {
+			return nil, err
+		}
+	}
+
+	return states, nil
 }
 
-// Activate globally activates the feature
-func (m *Manager) Activate(feature *Feature) error {
-	if err := m.get(feature); err != nil {
-		return err
+// cached returns the state of the feature from the snapshot when polling, otherwise from the cache
+// when enabled, refreshing it in the background when stale
+func (m *Manager) cached(feature *Feature) (*Feature, bool) {
+	if snap := m.snapshot.Load(); snap != nil {
+		if state, ok := snap.features[feature.Name()]; ok {
+			return state, true
+		}
+
+		// feature isn't in the store, so should be in its default state
+		return feature.blank(), true
 	}
 
-	feature.Lock()
-	feature.activate()
+	if m.cache == nil {
+		return nil, false
+	}
+
+	state, refresh, ok := m.cache.get(m.keyName(feature), m.now())
+	if !ok {
+		return nil, false
+	}
+	if refresh {
+		go m.refresh(feature)
+	}
+
+	return state.(*Feature), true
+}
 
-	data, err := msgpack.Marshal(feature)
+// fetched decodes the data fetched from the store for the feature, caching it when enabled
+func (m *Manager) fetched(feature *Feature, data []byte, fetchedAt time.Time) (*Feature, error) {
+	state, err := m.decode(feature, data)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	feature.Unlock()
 
-	if err := m.client.Set(m.keyName(feature), data, 0).Err(); err != nil {
-		return err
+	if m.cache != nil {
+		m.cache.set(m.keyName(feature), state, fetchedAt)
+	}
+	if m.fallback == FallbackLastKnown {
+		m.lastKnown.Store(m.keyName(feature), state)
+	}
+
+	return state, nil
+}
+
+// fallbackState returns the state of the feature to evaluate when the store couldn't be reached
+func (m *Manager) fallbackState(feature *Feature, err error) (*Feature, error) {
+	switch m.fallback {
+	case FallbackLastKnown:
+		if state, ok := m.lastKnown.Load(m.keyName(feature)); ok {
+			return state.(*Feature), nil
+		}
+		return feature.blank(), nil
+
+	case FallbackDefault:
+		return feature.blank(), nil
+
+	case FallbackClosed:
+		return NewFeature(feature.Name()), nil
+
+	default:
+		return nil, err
+	}
+}
+
+// refresh fetches the feature from the store in the background to replace the stale cached copy
+func (m *Manager) refresh(feature *Feature) {
+	key := m.keyName(feature)
+	defer m.cache.done(key)
+
+	fetchedAt := m.now()
+	data, err := m.store.Get(context.Background(), key)
+	if err != nil {
+		// keep serving the stale feature, the next evaluation retries the refresh
+		return
+	}
+
+	// a feature that can't be decoded is fetched again by the next evaluation once too stale
+	_, _ = m.fetched(feature, data, fetchedAt)
+}
+
+// updated caches the data written to the store for the feature and announces the change
+func (m *Manager) updated(ctx context.Context, feature *Feature, data []byte) error {
+	if data == nil {
+		// deleted features are evaluated in the default declared by whoever evaluates them, rather than this one's
+		if m.cache != nil {
+			m.cache.delete(m.keyName(feature))
+		}
+		m.unsnapshotted(feature)
+	} else if m.cache != nil || m.snapshot.Load() != nil {
+		state, err := m.decode(feature, data)
+		if err != nil {
+			return err
+		}
+
+		if m.cache != nil {
+			m.cache.set(m.keyName(feature), state, m.now())
+		}
+		m.snapshotted(feature, state)
+	}
+
+	if m.notifier != nil {
+		if err := m.notifier.Publish(ctx, m.channelName(), feature.Name()); err != nil {
+			return &NotifyError{Feature: feature.Name(), Err: err}
+		}
 	}
 
 	return nil
 }
 
-// Deactivate globally deactivates the feature
-func (m *Manager) Deactivate(feature *Feature) error {
-	if err := m.get(feature); err != nil {
-		return err
+// Subscribe listens for changes announced by other Managers and the CLI sharing the store, dropping the
+// changed features and segments from the cache so they're fetched again by the next evaluation, and fetching them into
+// the snapshot when polling. It returns once subscribed, listening in the background until the context is cancelled.
+func (m *Manager) Subscribe(ctx context.Context) error {
+	if m.notifier == nil {
+		return errors.New("store doesn't support change notifications")
 	}
 
-	feature.Lock()
-	feature.deactivate()
+	// both channels are unsubscribed from when either fails to subscribe
+	ctx, cancel := context.WithCancel(ctx)
 
-	data, err := msgpack.Marshal(feature)
+	messages, err := m.notifier.Subscribe(ctx, m.channelName())
 	if err != nil {
+		cancel()
 		return err
 	}
-	feature.Unlock()
 
-	if err := m.client.Set(m.keyName(feature), data, 0).Err(); err != nil {
+	segmentMessages, err := m.notifier.Subscribe(ctx, m.segmentChannelName())
+	if err != nil {
+		cancel()
 		return err
 	}
 
+	go func() {
+		defer cancel()
+
+		for name := range segmentMessages {
+			m.segmentChanged(ctx, name)
+		}
+	}()
+
+	go func() {
+		for name := range messages {
+			feature := NewFeature(name)
+
+			if m.cache != nil {
+				m.cache.delete(m.keyName(feature))
+			}
+
+			if m.snapshot.Load() != nil {
+				data, err := m.store.Get(ctx, m.keyName(feature))
+				if err != nil {
+					// the next snapshot refresh picks up the change
+					continue
+				}
+
+				if data == nil {
+					m.unsnapshotted(feature)
+				} else if state, err := m.decode(feature, data); err == nil {
+					m.snapshotted(feature, state)
+				}
+			}
+		}
+	}()
+
 	return nil
 }
 
+// update atomically applies the change to the feature, re-reading and retrying whenever the feature was
+// modified in the store by someone else in the meantime, and records the change in the history as the operation
+func (m *Manager) update(ctx context.Context, feature *Feature, operation string, change func(f *Feature)) error {
+	return m.tryUpdate(ctx, feature, operation, func(f *Feature) error {
+		change(f)
+		return nil
+	})
+}
+
+// tryUpdate is update with a change which can fail, e.g. when what it changes isn't there anymore,
+// in which case the feature is left unchanged in the store and the error returned
+func (m *Manager) tryUpdate(ctx context.Context, feature *Feature, operation string, change func(f *Feature) error) error {
+	for attempt := 0; attempt < maxUpdateAttempts; attempt++ {
+		current, err := m.store.Get(ctx, m.keyName(feature))
+		if err != nil {
+			return err
+		}
+
+		var version int64
+		if current == nil {
+			// versions carry on from the history of the feature when it was deleted, or start over when it can't be read
+			version = m.lastVersion(ctx, feature)
+		}
+
+		feature.Lock()
+		if err := feature.load(current); err != nil {
+			feature.Unlock()
+			return err
+		}
+		// changes are made on top of the scheduled changes which are due and the current step of the ramp,
+		// rather than have those made after them
+		now := m.now()
+		feature.advance(now)
+		version = max(feature.version, version) + 1
+		if err := change(feature); err != nil {
+			feature.Unlock()
+			return err
+		}
+		feature.touch(authorFromContext(ctx), now)
+		feature.version = version
+		data, err := m.encode(feature, current)
+		feature.Unlock()
+		if err != nil {
+			return err
+		}
+
+		swapped, err := m.store.CompareAndSet(ctx, m.keyName(feature), current, data)
+		if err != nil {
+			return err
+		}
+		if swapped {
+			// the change is made whether or not it's recorded, so it's announced either way
+			recordErr := m.record(ctx, m.historyKeyName(feature), historyRecord{
+				Version:   version,
+				At:        now,
+				Author:    authorFromContext(ctx),
+				Operation: operation,
+				Reason:    reasonFromContext(ctx),
+				Before:    current,
+				After:     data,
+			})
+
+			if err := m.updated(ctx, feature, data); err != nil {
+				return err
+			}
+
+			return m.historyError(feature, recordErr)
+		}
+	}
+
+	return &ConflictError{Feature: feature.Name(), Attempts: maxUpdateAttempts}
+}
+
+// Activate globally activates the feature
+func (m *Manager) Activate(feature *Feature) error {
+	return m.ActivateContext(context.Background(), feature)
+}
+
+// ActivateContext globally activates the feature, bounded by the context
+func (m *Manager) ActivateContext(ctx context.Context, feature *Feature) error {
+	return m.update(ctx, feature, operation("activate"), (*Feature).activate)
+}
+
+// Deactivate globally deactivates the feature
+func (m *Manager) Deactivate(feature *Feature) error {
+	return m.DeactivateContext(context.Background(), feature)
+}
+
+// DeactivateContext globally deactivates the feature, bounded by the context
+func (m *Manager) DeactivateContext(ctx context.Context, feature *Feature) error {
+	return m.update(ctx, feature, operation("deactivate"), (*Feature).deactivate)
+}
+
 // ActivatePercentage activates the feature for a percentage of teams
 func (m *Manager) ActivatePercentage(feature *Feature, percentage uint8) error {
-	if err := m.get(feature); err != nil {
-		return err
-	}
+	return m.ActivatePercentageContext(context.Background(), feature, percentage)
+}
 
-	feature.Lock()
-	feature.activatePercentage(percentage)
+// ActivatePercentageContext activates the feature for a percentage of teams, bounded by the context
+func (m *Manager) ActivatePercentageContext(ctx context.Context, feature *Feature, percentage uint8) error {
+	return m.update(ctx, feature, operation("activate-percentage", percentage), func(f *Feature) {
+		f.activatePercentage(percentage)
+	})
+}
 
-	data, err := msgpack.Marshal(feature)
-	if err != nil {
+// ActivateBasisPoints activates the feature for hundredths of a percent of teams, e.g. 5 for 0.05%
+func (m *Manager) ActivateBasisPoints(feature *Feature, basisPoints uint16) error {
+	return m.ActivateBasisPointsContext(context.Background(), feature, basisPoints)
+}
+
+// ActivateBasisPointsContext activates the feature for hundredths of a percent of teams, bounded by the context
+func (m *Manager) ActivateBasisPointsContext(ctx context.Context, feature *Feature, basisPoints uint16) error {
+	return m.update(ctx, feature, operation("activate-percentage", float64(basisPoints)/100), func(f *Feature) {
+		f.activateBasisPoints(basisPoints)
+	})
+}
+
+// ActivateTeam activates the feature for specific team
+func (m *Manager) ActivateTeam(teamID int64, feature *Feature) error {
+	return m.ActivateTeamContext(context.Background(), teamID, feature)
+}
+
+// ActivateTeamContext activates the feature for specific team, bounded by the context
+func (m *Manager) ActivateTeamContext(ctx context.Context, teamID int64, feature *Feature) error {
+	return m.update(ctx, feature, operation("activate-team", teamID), func(f *Feature) {
+		f.activateTeam(teamID)
+	})
+}
+
+// DeactivateTeam deactivates the feature for specific team
+func (m *Manager) DeactivateTeam(teamID int64, feature *Feature) error {
+	return m.DeactivateTeamContext(context.Background(), teamID, feature)
+}
+
+// DeactivateTeamContext deactivates the feature for specific team, bounded by the context
+func (m *Manager) DeactivateTeamContext(ctx context.Context, teamID int64, feature *Feature) error {
+	return m.update(ctx, feature, operation("deactivate-team", teamID), func(f *Feature) {
+		f.deactivateTeam(teamID)
+	})
+}
+
+// BlockTeam prevents the feature from being active for a specific team, overriding any other activation
+func (m *Manager) BlockTeam(teamID int64, feature *Feature) error {
+	return m.BlockTeamContext(context.Background(), teamID, feature)
+}
+
+// BlockTeamContext prevents the feature from being active for a specific team, bounded by the context
+func (m *Manager) BlockTeamContext(ctx context.Context, teamID int64, feature *Feature) error {
+	return m.update(ctx, feature, operation("block-team", teamID), func(f *Feature) {
+		f.blockTeam(teamID)
+	})
+}
+
+// UnblockTeam allows the feature to be active for a specific blocked team again
+func (m *Manager) UnblockTeam(teamID int64, feature *Feature) error {
+	return m.UnblockTeamContext(context.Background(), teamID, feature)
+}
+
+// UnblockTeamContext allows the feature to be active for a specific blocked team again, bounded by the context
+func (m *Manager) UnblockTeamContext(ctx context.Context, teamID int64, feature *Feature) error {
+	return m.update(ctx, feature, operation("unblock-team", teamID), func(f *Feature) {
+		f.unblockTeam(teamID)
+	})
+}
+
+// AddRule adds a targeting rule activating the feature for everything with attributes matching all of its clauses
+func (m *Manager) AddRule(feature *Feature, rule Rule) error {
+	return m.AddRuleContext(context.Background(), feature, rule)
+}
+
+// AddRuleContext adds a targeting rule to the feature, bounded by the context
+func (m *Manager) AddRuleContext(ctx context.Context, feature *Feature, rule Rule) error {
+	if err := rule.validate(); err != nil {
 		return err
 	}
-	feature.Unlock()
 
-	if err := m.client.Set(m.keyName(feature), data, 0).Err(); err != nil {
+	return m.update(ctx, feature, operation("add-rule", rule), func(f *Feature) {
+		f.addRule(rule)
+	})
+}
+
+// RemoveRule removes the targeting rule from the feature, returning an error when the feature has no such rule
+func (m *Manager) RemoveRule(feature *Feature, rule Rule) error {
+	return m.RemoveRuleContext(context.Background(), feature, rule)
+}
+
+// RemoveRuleContext removes the targeting rule from the feature, returning an error when the feature has no such rule,
+// bounded by the context. The rule is matched by its clauses rather than its index, which changes as rules are added and removed.
+func (m *Manager) RemoveRuleContext(ctx context.Context, feature *Feature, rule Rule) error {
+	return m.tryUpdate(ctx, feature, operation("remove-rule", rule), func(f *Feature) error {
+		if !f.removeRule(rule) {
+			return fmt.Errorf("feature %q has no rule %q", f.Name(), rule)
+		}
+		return nil
+	})
+}
+
+// SetSalt sets the salt actor ids are prefixed with when bucketing the feature. Features sharing a salt
+// are rolled out to the same actors at the same percentage, regardless of randomizing percentage.
+// The empty salt restores bucketing by the feature name or actor id alone.
+func (m *Manager) SetSalt(feature *Feature, salt string) error {
+	return m.SetSaltContext(context.Background(), feature, salt)
+}
+
+// SetSaltContext sets the salt actor ids are prefixed with when bucketing the feature, bounded by the context
+func (m *Manager) SetSaltContext(ctx context.Context, feature *Feature, salt string) error {
+	return m.update(ctx, feature, operation("set-salt", salt), func(f *Feature) {
+		f.salt = salt
+	})
+}
+
+// SetHash sets the algorithm actors are bucketed with for the feature, which changes the actors
+// a percentage rollout is active for
+func (m *Manager) SetHash(feature *Feature, hash Hash) error {
+	return m.SetHashContext(context.Background(), feature, hash)
+}
+
+// SetHashContext sets the algorithm actors are bucketed with for the feature, bounded by the context
+func (m *Manager) SetHashContext(ctx context.Context, feature *Feature, hash Hash) error {
+	if err := hash.validate(); err != nil {
 		return err
 	}
 
-	return nil
+	return m.update(ctx, feature, operation("set-hash", hash), func(f *Feature) {
+		f.hash = hash
+	})
 }
 
-// IsActive returns whether the given feature is globally active
-func (m *Manager) IsActive(feature *Feature) (bool, error) {
-	// retrieve feature from redis
-	data, err := m.client.Get(m.keyName(feature)).Bytes()
+// ActivateActor activates the feature for a specific actor
+func (m *Manager) ActivateActor(actor Actor, feature *Feature) error {
+	return m.ActivateActorContext(context.Background(), actor, feature)
+}
+
+// ActivateActorContext activates the feature for a specific actor, bounded by the context
+func (m *Manager) ActivateActorContext(ctx context.Context, actor Actor, feature *Feature) error {
+	return m.update(ctx, feature, operation("activate-actor", actor), func(f *Feature) {
+		f.activateActor(actor)
+	})
+}
+
+// DeactivateActor deactivates the feature for a specific actor
+func (m *Manager) DeactivateActor(actor Actor, feature *Feature) error {
+	return m.DeactivateActorContext(context.Background(), actor, feature)
+}
+
+// DeactivateActorContext deactivates the feature for a specific actor, bounded by the context
+func (m *Manager) DeactivateActorContext(ctx context.Context, actor Actor, feature *Feature) error {
+	return m.update(ctx, feature, operation("deactivate-actor", actor), func(f *Feature) {
+		f.deactivateActor(actor)
+	})
+}
+
+// ActivateActorPercentage activates the feature for a percentage of actors of the kind.
+// The percentage of teams is the percentage of the feature, see ActivatePercentage.
+func (m *Manager) ActivateActorPercentage(kind ActorKind, feature *Feature, percentage uint8) error {
+	return m.ActivateActorPercentageContext(context.Background(), kind, feature, percentage)
+}
+
+// ActivateActorPercentageContext activates the feature for a percentage of actors of the kind, bounded by the context
+func (m *Manager) ActivateActorPercentageContext(ctx context.Context, kind ActorKind, feature *Feature, percentage uint8) error {
+	return m.update(ctx, feature, operation("activate-actor-percentage", kind, percentage), func(f *Feature) {
+		f.activateActorPercentage(kind, percentage)
+	})
+}
+
+// Delete removes the feature from the store, reporting whether it was found
+func (m *Manager) Delete(feature *Feature) (bool, error) {
+	return m.DeleteContext(context.Background(), feature)
+}
+
+// DeleteContext removes the feature from the store, reporting whether it was found, bounded by the context
+func (m *Manager) DeleteContext(ctx context.Context, feature *Feature) (bool, error) {
+	var current []byte
+	var recordErr error
+	if m.journal != nil {
+		// read for the history, which records the state the feature was deleted in
+		current, recordErr = m.store.Get(ctx, m.keyName(feature))
+	}
+
+	deleted, err := m.store.Delete(ctx, m.keyName(feature))
 	if err != nil {
-		if err == redis.Nil {
-			return false, nil
-		}
 		return false, err
 	}
 
-	feature.Lock()
-	defer feature.Unlock()
-	if err := msgpack.Unmarshal(data, feature); err != nil {
-		return false, err
+	if deleted && m.journal != nil && recordErr == nil {
+		var state *Feature
+		if state, recordErr = m.decode(feature, current); recordErr == nil {
+			recordErr = m.record(ctx, m.historyKeyName(feature), historyRecord{
+				Version:   state.version + 1,
+				At:        m.now(),
+				Author:    authorFromContext(ctx),
+				Operation: operation("delete"),
+				Reason:    reasonFromContext(ctx),
+				Before:    current,
+			})
+		}
 	}
 
-	return feature.isActive(), nil
-}
+	feature.Lock()
+	feature.reset()
+	feature.Unlock()
 
-// IsActiveMulti returns whether the given features are globally active
-func (m *Manager) IsActiveMulti(features ...*Feature) ([]bool, error) {
-	if len(features) == 0 {
-		return nil, nil
+	if err := m.updated(ctx, feature, nil); err != nil {
+		return deleted, err
 	}
 
-	featureNames := make([]string, len(features))
-	for i, feature := range features {
-		featureNames[i] = m.keyName(feature)
+	if !deleted {
+		return false, nil
 	}
 
-	// retrieve features from redis
-	val, err := m.client.MGet(featureNames...).Result()
+	return true, m.historyError(feature, recordErr)
+}
+
+// List returns every feature persisted under the key prefix, sorted by name
+func (m *Manager) List() ([]*Feature, error) {
+	return m.ListContext(context.Background())
+}
+
+// ListContext returns every feature persisted under the key prefix, sorted by name, bounded by the context.
+// Features are returned in their current state, with the scheduled changes which are due made and the rollout their ramp is at.
+func (m *Manager) ListContext(ctx context.Context) ([]*Feature, error) {
+	prefix := m.keyPrefix + ":"
+
+	keys, err := m.store.List(ctx, prefix)
 	if err != nil {
 		return nil, err
 	}
-
-	for _, feature := range features {
-		feature.Lock()
-		defer feature.Unlock()
+	if len(keys) == 0 {
+		return nil, nil
 	}
 
-	results := make([]bool, len(features))
+	sort.Strings(keys)
 
-	for i, v := range val {
-		switch t := v.(type) {
-		case nil:
-			// feature wasn't found in redis, so considered inactive globally
-			features[i].deactivate()
+	val, err := m.store.MGet(ctx, keys...)
+	if err != nil {
+		return nil, err
+	}
 
-		case string:
-			if err := msgpack.Unmarshal([]byte(t), features[i]); err != nil {
-				return nil, err
-			}
-			results[i] = features[i].isActive()
+	now := m.now()
+	features := make([]*Feature, 0, len(keys))
+	for i, key := range keys {
+		if val[i] == nil {
+			// feature was deleted since listing the keys
+			continue
+		}
 
-		default:
-			return nil, fmt.Errorf("unexpected type (%T) for msgpack value: %v", v, v)
+		feature := NewFeature(strings.TrimPrefix(key, prefix))
+		if err := feature.load(val[i]); err != nil {
+			return nil, err
 		}
+		feature.advance(now)
+		features = append(features, feature)
 	}
 
-	return results, nil
+	return features, nil
 }
 
-// ActivateTeam activates the feature for specific team
-func (m *Manager) ActivateTeam(teamID int64, feature *Feature) error {
-	if err := m.get(feature); err != nil {
-		return err
+// Get returns the feature as it's persisted, nil when it isn't
+func (m *Manager) Get(feature *Feature) (*Feature, error) {
+	return m.GetContext(context.Background(), feature)
+}
+
+// GetContext returns the feature as it's persisted, nil when it isn't, bounded by the context. The feature is read
+// from the store rather than the cache or snapshot, and returned in its current state like the features of List.
+func (m *Manager) GetContext(ctx context.Context, feature *Feature) (*Feature, error) {
+	data, err := m.store.Get(ctx, m.keyName(feature))
+	if err != nil || data == nil {
+		return nil, err
 	}
 
-	feature.Lock()
-	feature.activateTeam(teamID)
+	state, err := m.decode(feature, data)
+	if err != nil {
+		return nil, err
+	}
+	state.advance(m.now())
+
+	return state, nil
+}
 
-	data, err := msgpack.Marshal(feature)
+// IsActive returns whether the given feature is globally active
+func (m *Manager) IsActive(feature *Feature) (bool, error) {
+	return m.IsActiveContext(context.Background(), feature)
+}
+
+// IsActiveContext returns whether the given feature is globally active, bounded by the context
+func (m *Manager) IsActiveContext(ctx context.Context, feature *Feature) (bool, error) {
+	state, err := m.load(ctx, feature)
 	if err != nil {
-		return err
+		return false, err
 	}
-	feature.Unlock()
 
-	if err := m.client.Set(m.keyName(feature), data, 0).Err(); err != nil {
-		return err
+	if !state.isActive() {
+		return false, nil
 	}
 
-	return nil
+	return m.prerequisitesActive(ctx, state, nil, nil)
 }
 
-// DeactivateTeam deactivates the feature for specific team
-func (m *Manager) DeactivateTeam(teamID int64, feature *Feature) error {
-	if err := m.get(feature); err != nil {
-		return err
-	}
+// IsActiveMulti returns whether the given features are globally active
+func (m *Manager) IsActiveMulti(features ...*Feature) ([]bool, error) {
+	return m.IsActiveMultiContext(context.Background(), features...)
+}
 
-	feature.Lock()
-	feature.deactivateTeam(teamID)
+// IsActiveMultiContext returns whether the given features are globally active, bounded by the context
+func (m *Manager) IsActiveMultiContext(ctx context.Context, features ...*Feature) ([]bool, error) {
+	if len(features) == 0 {
+		return nil, nil
+	}
 
-	data, err := msgpack.Marshal(feature)
+	states, err := m.loadMulti(ctx, features...)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	feature.Unlock()
 
-	if err := m.client.Set(m.keyName(feature), data, 0).Err(); err != nil {
-		return err
+	results := make([]bool, len(features))
+	for i, state := range states {
+		if !state.isActive() {
+			continue
+		}
+
+		if results[i], err = m.prerequisitesActive(ctx, state, nil, nil); err != nil {
+			return nil, err
+		}
 	}
 
-	return nil
+	return results, nil
 }
 
 // IsTeamActive returns whether the given feature is active for a team
 func (m *Manager) IsTeamActive(teamID int64, feature *Feature) (bool, error) {
-	// retrieve feature from redis
-	data, err := m.client.Get(m.keyName(feature)).Bytes()
+	return m.IsTeamActiveContext(context.Background(), teamID, feature)
+}
+
+// IsTeamActiveContext returns whether the given feature is active for a team, bounded by the context
+func (m *Manager) IsTeamActiveContext(ctx context.Context, teamID int64, feature *Feature) (bool, error) {
+	return m.IsActorActiveContext(ctx, Team(teamID), feature)
+}
+
+// IsTeamActiveMulti returns whether the given features are active for a team
+func (m *Manager) IsTeamActiveMulti(teamID int64, features ...*Feature) ([]bool, error) {
+	return m.IsTeamActiveMultiContext(context.Background(), teamID, features...)
+}
+
+// IsTeamActiveMultiContext returns whether the given features are active for a team, bounded by the context
+func (m *Manager) IsTeamActiveMultiContext(ctx context.Context, teamID int64, features ...*Feature) ([]bool, error) {
+	return m.IsActorActiveMultiContext(ctx, Team(teamID), features...)
+}
+
+// IsActorActive returns whether the given feature is active for an actor
+func (m *Manager) IsActorActive(actor Actor, feature *Feature) (bool, error) {
+	return m.IsActorActiveContext(context.Background(), actor, feature)
+}
+
+// IsActorActiveContext returns whether the given feature is active for an actor, bounded by the context
+func (m *Manager) IsActorActiveContext(ctx context.Context, actor Actor, feature *Feature) (bool, error) {
+	return m.IsActorActiveWithAttributesContext(ctx, actor, nil, feature)
+}
+
+// IsActorActiveMulti returns whether the given features are active for an actor
+func (m *Manager) IsActorActiveMulti(actor Actor, features ...*Feature) ([]bool, error) {
+	return m.IsActorActiveMultiContext(context.Background(), actor, features...)
+}
+
+// IsActorActiveMultiContext returns whether the given features are active for an actor, bounded by the context
+func (m *Manager) IsActorActiveMultiContext(ctx context.Context, actor Actor, features ...*Feature) ([]bool, error) {
+	return m.IsActorActiveMultiWithAttributesContext(ctx, actor, nil, features...)
+}
+
+// IsTeamActiveWithAttributes returns whether the given feature is active for a team with the attributes,
+// which are matched against the targeting rules of the feature
+func (m *Manager) IsTeamActiveWithAttributes(teamID int64, attributes Attributes, feature *Feature) (bool, error) {
+	return m.IsTeamActiveWithAttributesContext(context.Background(), teamID, attributes, feature)
+}
+
+// IsTeamActiveWithAttributesContext returns whether the given feature is active for a team with the attributes, bounded by the context
+func (m *Manager) IsTeamActiveWithAttributesContext(ctx context.Context, teamID int64, attributes Attributes, feature *Feature) (bool, error) {
+	return m.IsActorActiveWithAttributesContext(ctx, Team(teamID), attributes, feature)
+}
+
+// IsTeamActiveMultiWithAttributes returns whether the given features are active for a team with the attributes
+func (m *Manager) IsTeamActiveMultiWithAttributes(teamID int64, attributes Attributes, features ...*Feature) ([]bool, error) {
+	return m.IsTeamActiveMultiWithAttributesContext(context.Background(), teamID, attributes, features...)
+}
+
+// IsTeamActiveMultiWithAttributesContext returns whether the given features are active for a team with the attributes, bounded by the context
+func (m *Manager) IsTeamActiveMultiWithAttributesContext(ctx context.Context, teamID int64, attributes Attributes, features ...*Feature) ([]bool, error) {
+	return m.IsActorActiveMultiWithAttributesContext(ctx, Team(teamID), attributes, features...)
+}
+
+// IsActorActiveWithAttributes returns whether the given feature is active for an actor with the attributes,
+// which are matched against the targeting rules of the feature
+func (m *Manager) IsActorActiveWithAttributes(actor Actor, attributes Attributes, feature *Feature) (bool, error) {
+	return m.IsActorActiveWithAttributesContext(context.Background(), actor, attributes, feature)
+}
+
+// IsActorActiveWithAttributesContext returns whether the given feature is active for an actor with the attributes, bounded by the context
+func (m *Manager) IsActorActiveWithAttributesContext(ctx context.Context, actor Actor, attributes Attributes, feature *Feature) (bool, error) {
+	state, err := m.load(ctx, feature)
 	if err != nil {
-		if err == redis.Nil {
-			return false, nil
-		}
 		return false, err
 	}
 
-	feature.Lock()
-	defer feature.Unlock()
-	if err := msgpack.Unmarshal(data, feature); err != nil {
+	segments, err := m.loadSegments(ctx, state)
+	if err != nil {
 		return false, err
 	}
 
-	return feature.isTeamActive(teamID, m.randomizePercentage), nil
+	return m.evaluate(ctx, state, m.evaluation(actor, attributes, segments))
 }
 
-// IsTeamActiveMulti returns whether the given features are globally active
-func (m *Manager) IsTeamActiveMulti(teamID int64, features ...*Feature) ([]bool, error) {
+// IsActorActiveMultiWithAttributes returns whether the given features are active for an actor with the attributes
+func (m *Manager) IsActorActiveMultiWithAttributes(actor Actor, attributes Attributes, features ...*Feature) ([]bool, error) {
+	return m.IsActorActiveMultiWithAttributesContext(context.Background(), actor, attributes, features...)
+}
+
+// IsActorActiveMultiWithAttributesContext returns whether the given features are active for an actor with the attributes, bounded by the context
+func (m *Manager) IsActorActiveMultiWithAttributesContext(ctx context.Context, actor Actor, attributes Attributes, features ...*Feature) ([]bool, error) {
 	if len(features) == 0 {
 		return nil, nil
 	}
 
-	featureNames := make([]string, len(features))
-	for i, feature := range features {
-		featureNames[i] = m.keyName(feature)
+	states, err := m.loadMulti(ctx, features...)
+	if err != nil {
+		return nil, err
 	}
 
-	// retrieve features from redis
-	val, err := m.client.MGet(featureNames...).Result()
+	segments, err := m.loadSegments(ctx, states...)
 	if err != nil {
 		return nil, err
 	}
 
-	for _, feature := range features {
-		feature.Lock()
-		defer feature.Unlock()
-	}
+	e := m.evaluation(actor, attributes, segments)
 
 	results := make([]bool, len(features))
-
-	for i, v := range val {
-		switch t := v.(type) {
-		case nil:
-			// feature wasn't found in redis, so considered inactive globally
-			features[i].deactivate()
-
-		case string:
-			if err := msgpack.Unmarshal([]byte(t), features[i]); err != nil {
-				return nil, err
-			}
-			results[i] = features[i].isTeamActive(teamID, m.randomizePercentage)
-
-		default:
-			return nil, fmt.Errorf("unexpected type (%T) for msgpack value: %v", v, v)
+	for i, state := range states {
+		if results[i], err = m.evaluate(ctx, state, e); err != nil {
+			return nil, err
 		}
 	}
 
 	return results, nil
 }
+
+// evaluate returns whether the feature is active for the actor of the evaluation, along with all of its prerequisites
+func (m *Manager) evaluate(ctx context.Context, state *Feature, e evaluation) (bool, error) {
+	if !state.evaluate(e) {
+		return false, nil
+	}
+
+	return m.prerequisitesActive(ctx, state, &e, nil)
+}
+
+// evaluation returns what features are evaluated for
+func (m *Manager) evaluation(actor Actor, attributes Attributes, segments map[string]*Segment) evaluation {
+	return evaluation{
+		actor:               actor,
+		attributes:          attributes,
+		randomizePercentage: m.randomizePercentage,
+		segments:            segments,
+	}
+}
@@ -1,7 +1,10 @@
 package rollout
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"strconv"
 	"testing"
 	"time"
 
@@ -22,7 +25,11 @@ type MockClient struct {
 	feature                      Feature
 	features                     []*Feature
 	shouldError                  bool
+	conflicts                    int
 	getWasCalled, setWasCalled   bool
+	delWasCalled                 bool
+	published                    []string
+	journal                      map[string][]redis.XMessage
 	mgetWasCalled, msetWasCalled bool
 }
 
@@ -84,19 +91,133 @@ func (c *MockClient) MGet(keys ...string) *redis.SliceCmd {
 	return redis.NewSliceResult(val, nil)
 }
 
-func (c *MockClient) Set(key string, value interface{}, expiration time.Duration) *redis.StatusCmd {
+func (c *MockClient) EvalSha(sha1 string, keys []string, args ...interface{}) *redis.Cmd {
 	c.setWasCalled = true
-	c.setKey = key
+	c.setKey = keys[0]
 
 	if c.shouldError {
-		return redis.NewStatusResult("", errors.New("mock error"))
+		return redis.NewCmdResult(nil, errors.New("mock error"))
 	}
 
-	if err := msgpack.Unmarshal(value.([]byte), &c.feature); err != nil {
-		return redis.NewStatusResult("", err)
+	if c.conflicts > 0 {
+		// mock the feature being modified by someone else since it was read
+		c.conflicts--
+		return redis.NewCmdResult(int64(0), nil)
 	}
 
-	return redis.NewStatusResult("", nil)
+	if err := msgpack.Unmarshal(args[1].([]byte), &c.feature); err != nil {
+		return redis.NewCmdResult(nil, err)
+	}
+
+	return redis.NewCmdResult(int64(1), nil)
+}
+
+func (c *MockClient) Del(keys ...string) *redis.IntCmd {
+	c.delWasCalled = true
+
+	if c.shouldError {
+		return redis.NewIntResult(0, errors.New("mock error"))
+	}
+
+	if c.feature.name != "" && mockKeyPrefix+":"+c.feature.name == keys[0] {
+		c.feature = Feature{}
+		return redis.NewIntResult(1, nil)
+	}
+
+	// mock not found
+	return redis.NewIntResult(0, nil)
+}
+
+func (c *MockClient) Scan(cursor uint64, match string, count int64) *redis.ScanCmd {
+	if c.shouldError {
+		return redis.NewScanCmdResult(nil, 0, errors.New("mock error"))
+	}
+
+	if cursor >= uint64(len(c.features)) {
+		return redis.NewScanCmdResult(nil, 0, nil)
+	}
+
+	// mock returning a single key per iteration
+	keys := []string{mockKeyPrefix + ":" + c.features[cursor].name}
+	cursor++
+	if cursor == uint64(len(c.features)) {
+		cursor = 0
+	}
+
+	return redis.NewScanCmdResult(keys, cursor, nil)
+}
+
+func (c *MockClient) Publish(channel string, message interface{}) *redis.IntCmd {
+	c.published = append(c.published, message.(string))
+
+	return redis.NewIntResult(0, nil)
+}
+
+func (c *MockClient) XAdd(a *redis.XAddArgs) *redis.StringCmd {
+	if c.journal == nil {
+		c.journal = make(map[string][]redis.XMessage)
+	}
+
+	// the stream holds the values as strings
+	values := make(map[string]interface{}, len(a.Values))
+	for field, value := range a.Values {
+		values[field] = string(value.([]byte))
+	}
+
+	id := strconv.Itoa(len(c.journal[a.Stream]) + 1)
+	c.journal[a.Stream] = append(c.journal[a.Stream], redis.XMessage{ID: id, Values: values})
+	if n := int(a.MaxLen); len(c.journal[a.Stream]) > n {
+		c.journal[a.Stream] = c.journal[a.Stream][len(c.journal[a.Stream])-n:]
+	}
+
+	return redis.NewStringResult(id, nil)
+}
+
+func (c *MockClient) XRange(stream, start, stop string) *redis.XMessageSliceCmd {
+	return redis.NewXMessageSliceCmdResult(c.journal[stream], nil)
+}
+
+func (c *MockClient) XRevRangeN(stream, start, stop string, count int64) *redis.XMessageSliceCmd {
+	messages := make([]redis.XMessage, 0, count)
+	for i := len(c.journal[stream]) - 1; i >= 0 && int64(len(messages)) < count; i-- {
+		messages = append(messages, c.journal[stream][i])
+	}
+
+	return redis.NewXMessageSliceCmdResult(messages, nil)
+}
+
+// failingStore is a Store failing to read while fail is set
+type failingStore struct {
+	Store
+	fail  bool
+	calls int
+}
+
+func (s *failingStore) Get(ctx context.Context, key string) ([]byte, error) {
+	s.calls++
+	if s.fail {
+		return nil, errors.New("mock error")
+	}
+
+	return s.Store.Get(ctx, key)
+}
+
+func (s *failingStore) MGet(ctx context.Context, keys ...string) ([][]byte, error) {
+	s.calls++
+	if s.fail {
+		return nil, errors.New("mock error")
+	}
+
+	return s.Store.MGet(ctx, keys...)
+}
+
+func (s *failingStore) List(ctx context.Context, prefix string) ([]string, error) {
+	s.calls++
+	if s.fail {
+		return nil, errors.New("mock error")
+	}
+
+	return s.Store.List(ctx, prefix)
 }
 
 func TestNewManager(t *testing.T) {
@@ -104,7 +225,7 @@ func TestNewManager(t *testing.T) {
 	assert.NotNil(t, manager)
 }
 
-func TestGet(t *testing.T) {
+func TestLoad(t *testing.T) {
 	client := &MockClient{}
 	manager := NewManager(client, mockKeyPrefix, false)
 
@@ -115,11 +236,38 @@ func TestGet(t *testing.T) {
 	}
 	f := NewFeature("example")
 
-	err := manager.get(f)
+	state, err := manager.load(context.Background(), f)
 	assert.NoError(t, err)
-	assert.Equal(t, uint8(50), f.percentage)
-	assert.Equal(t, struct{}{}, f.teamIDs[1])
-	assert.Equal(t, struct{}{}, f.teamIDs[2])
+	assert.True(t, client.getWasCalled)
+	assert.Equal(t, uint8(50), state.percentage)
+	assert.Equal(t, struct{}{}, state.teamIDs[1])
+	assert.Equal(t, struct{}{}, state.teamIDs[2])
+}
+
+func TestManagerGet(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := NewMemoryStore()
+	manager := NewManagerWithStore(store, mockKeyPrefix, false, WithCache(time.Hour, time.Hour))
+	manager.now = func() time.Time { return now }
+
+	f := NewFeature("example", WithDefault(true))
+	state, err := manager.Get(f)
+	assert.NoError(t, err)
+	assert.Nil(t, state)
+
+	assert.NoError(t, manager.ActivateTeam(1, f))
+	assert.NoError(t, manager.ScheduleBasisPoints(f, now.Add(time.Hour), 2500))
+
+	// read from the store rather than the cache, in the current state
+	assert.NoError(t, NewManagerWithStore(store, mockKeyPrefix, false).ActivateTeam(2, NewFeature("example")))
+	now = now.Add(time.Hour)
+
+	state, err = manager.Get(f)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{1, 2}, state.TeamIDs())
+	assert.EqualValues(t, 2500, state.BasisPoints())
+	assert.Empty(t, state.Schedule())
+	assert.True(t, state.Default())
 }
 
 func TestActivate(t *testing.T) {
@@ -135,6 +283,7 @@ func TestActivate(t *testing.T) {
 	assert.True(t, f.isActive())
 	assert.True(t, client.setWasCalled)
 	assert.Equal(t, mockKeyPrefix+":example", client.setKey)
+	assert.Equal(t, []string{"example"}, client.published)
 
 	// mock error
 	client.setWasCalled = false
@@ -203,6 +352,140 @@ func TestActivatePercentage(t *testing.T) {
 	assert.EqualError(t, err, "mock error")
 }
 
+func TestActivateBasisPoints(t *testing.T) {
+	manager := NewManagerWithStore(NewMemoryStore(), mockKeyPrefix, true)
+
+	f := NewFeature("example")
+	assert.NoError(t, manager.ActivateBasisPoints(f, 5))
+	assert.EqualValues(t, 5, f.BasisPoints())
+
+	var active int
+	for teamID := int64(0); teamID < 100000; teamID++ {
+		if ok, err := manager.IsTeamActive(teamID, f); assert.NoError(t, err) && ok {
+			active++
+		}
+	}
+	assert.InDelta(t, 50, active, 25)
+
+	// whole percentages replace the fraction
+	assert.NoError(t, manager.ActivatePercentage(f, 100))
+	assert.EqualValues(t, MaxBasisPoints, f.BasisPoints())
+}
+
+func TestRules(t *testing.T) {
+	manager := NewManagerWithStore(NewMemoryStore(), mockKeyPrefix, false)
+
+	plan, err := ParseClause("plan in pro,enterprise")
+	assert.NoError(t, err)
+	seats, err := ParseClause("seats gte 50")
+	assert.NoError(t, err)
+
+	f := NewFeature("example")
+	assert.NoError(t, manager.AddRule(f, Rule{plan, seats}))
+	assert.EqualError(t, manager.AddRule(f, Rule{}), "rule has no clauses")
+
+	active, err := manager.IsTeamActiveWithAttributes(1, Attributes{"plan": "pro", "seats": 75}, f)
+	assert.NoError(t, err)
+	assert.True(t, active)
+
+	results, err := manager.IsTeamActiveMultiWithAttributes(1, Attributes{"plan": "pro", "seats": 10}, f)
+	assert.NoError(t, err)
+	assert.Equal(t, []bool{false}, results)
+
+	results, err = manager.IsActorActiveMultiWithAttributes(NewActor(KindUser, "abc"), Attributes{"plan": "enterprise", "seats": 50}, f, NewFeature("other"))
+	assert.NoError(t, err)
+	assert.Equal(t, []bool{true, false}, results)
+
+	// evaluations without attributes don't match the rules
+	active, err = manager.IsTeamActive(1, f)
+	assert.NoError(t, err)
+	assert.False(t, active)
+
+	// rules are removed by their clauses, so rules added or removed concurrently don't shift the one removed
+	other, err := ParseClause("country eq CA")
+	assert.NoError(t, err)
+	assert.NoError(t, NewManagerWithStore(manager.store, mockKeyPrefix, false).AddRule(NewFeature("example"), Rule{other}))
+	assert.NoError(t, manager.RemoveRule(f, Rule{plan, seats}))
+	assert.Equal(t, []Rule{{other}}, f.Rules())
+	assert.EqualError(t, manager.RemoveRule(f, Rule{plan, seats}), `feature "example" has no rule "plan in pro,enterprise && seats gte 50"`)
+	assert.Equal(t, []Rule{{other}}, f.Rules())
+	assert.NoError(t, manager.RemoveRule(f, Rule{other}))
+
+	active, err = manager.IsActorActiveWithAttributes(Team(1), Attributes{"plan": "pro", "seats": 75}, f)
+	assert.NoError(t, err)
+	assert.False(t, active)
+}
+
+func TestSetSaltAndHash(t *testing.T) {
+	manager := NewManagerWithStore(NewMemoryStore(), mockKeyPrefix, false)
+
+	f := NewFeature("example")
+	assert.NoError(t, manager.SetSalt(f, "cohort"))
+	assert.NoError(t, manager.SetHash(f, HashXXHash))
+	assert.EqualError(t, manager.SetHash(f, "md5"), `unknown hash "md5"`)
+
+	assert.NoError(t, manager.ActivatePercentage(f, 50))
+	assert.Equal(t, "cohort", f.Salt())
+	assert.Equal(t, HashXXHash, f.Hash())
+
+	active, err := manager.IsTeamActive(1, f)
+	assert.NoError(t, err)
+	assert.Equal(t, f.isTeamActive(1, false), active)
+}
+
+func TestActivateConflict(t *testing.T) {
+	client := &MockClient{}
+	manager := NewManager(client, mockKeyPrefix, false)
+
+	f := NewFeature("example")
+
+	// retried until the change applies
+	client.conflicts = 2
+	err := manager.ActivateTeam(1, f)
+	assert.NoError(t, err)
+	assert.True(t, f.isTeamActive(1, manager.randomizePercentage))
+	assert.Equal(t, 0, client.conflicts)
+
+	// gives up once the retries are exhausted
+	client.conflicts = maxUpdateAttempts
+	err = manager.ActivateTeam(2, f)
+	var conflict *ConflictError
+	assert.True(t, errors.As(err, &conflict))
+	assert.Equal(t, "example", conflict.Feature)
+	assert.Equal(t, maxUpdateAttempts, conflict.Attempts)
+	assert.EqualError(t, err, fmt.Sprintf("feature %q was modified concurrently, gave up after %d attempts", "example", maxUpdateAttempts))
+}
+
+func TestDelete(t *testing.T) {
+	client := &MockClient{}
+	manager := NewManager(client, mockKeyPrefix, false)
+
+	f := NewFeature("example")
+
+	// feature not in redis
+	deleted, err := manager.Delete(f)
+	assert.NoError(t, err)
+	assert.False(t, deleted)
+	assert.True(t, client.delWasCalled)
+
+	// feature in redis
+	client.delWasCalled = false
+	client.feature = Feature{name: "example", percentage: 100}
+	f.activate()
+	deleted, err = manager.Delete(f)
+	assert.NoError(t, err)
+	assert.True(t, deleted)
+	assert.False(t, f.isActive())
+	assert.True(t, client.delWasCalled)
+
+	// mock error
+	client.delWasCalled = false
+	client.shouldError = true
+	_, err = manager.Delete(f)
+	assert.EqualError(t, err, "mock error")
+	assert.True(t, client.delWasCalled)
+}
+
 func TestIsActive(t *testing.T) {
 	client := &MockClient{}
 	manager := NewManager(client, mockKeyPrefix, false)
@@ -439,6 +722,85 @@ func TestIsTeamActiveMulti(t *testing.T) {
 	assert.True(t, client.mgetWasCalled)
 }
 
+func TestBlockTeam(t *testing.T) {
+	manager := NewManagerWithStore(NewMemoryStore(), mockKeyPrefix, false)
+
+	f := NewFeature("example")
+	assert.NoError(t, manager.Activate(NewFeature("example")))
+	assert.NoError(t, manager.BlockTeam(1, NewFeature("example")))
+
+	results, err := manager.IsTeamActiveMulti(1, f)
+	assert.NoError(t, err)
+	assert.Equal(t, []bool{false}, results)
+
+	active, err := manager.IsTeamActive(2, f)
+	assert.NoError(t, err)
+	assert.True(t, active)
+
+	assert.NoError(t, manager.UnblockTeam(1, NewFeature("example")))
+
+	active, err = manager.IsTeamActive(1, f)
+	assert.NoError(t, err)
+	assert.True(t, active)
+}
+
+func TestActors(t *testing.T) {
+	manager := NewManagerWithStore(NewMemoryStore(), mockKeyPrefix, false)
+
+	f := NewFeature("example")
+	user := NewActor(KindUser, "abc")
+
+	active, err := manager.IsActorActive(user, f)
+	assert.NoError(t, err)
+	assert.False(t, active)
+
+	assert.NoError(t, manager.ActivateActor(user, NewFeature("example")))
+	assert.NoError(t, manager.ActivateActor(Team(1), NewFeature("example")))
+
+	active, err = manager.IsActorActive(user, f)
+	assert.NoError(t, err)
+	assert.True(t, active)
+
+	active, err = manager.IsTeamActive(1, f)
+	assert.NoError(t, err)
+	assert.True(t, active)
+
+	results, err := manager.IsActorActiveMulti(user, f, NewFeature("other"))
+	assert.NoError(t, err)
+	assert.Equal(t, []bool{true, false}, results)
+
+	assert.NoError(t, manager.ActivateActorPercentage(KindAccount, NewFeature("example"), 100))
+
+	active, err = manager.IsActorActive(NewActor(KindAccount, "xyz"), f)
+	assert.NoError(t, err)
+	assert.True(t, active)
+
+	assert.NoError(t, manager.DeactivateActor(user, NewFeature("example")))
+
+	active, err = manager.IsActorActive(user, f)
+	assert.NoError(t, err)
+	assert.False(t, active)
+}
+
+func TestList(t *testing.T) {
+	manager := NewManagerWithStore(NewMemoryStore(), mockKeyPrefix, false)
+
+	features, err := manager.List()
+	assert.NoError(t, err)
+	assert.Empty(t, features)
+
+	assert.NoError(t, manager.ActivatePercentage(NewFeature("second"), 50))
+	assert.NoError(t, manager.ActivateActor(NewActor(KindUser, "abc"), NewFeature("first")))
+
+	features, err = manager.List()
+	assert.NoError(t, err)
+	assert.Len(t, features, 2)
+	assert.Equal(t, "first", features[0].Name())
+	assert.Equal(t, []Actor{NewActor(KindUser, "abc")}, features[0].Actors())
+	assert.Equal(t, "second", features[1].Name())
+	assert.EqualValues(t, 50, features[1].Percentage())
+}
+
 func TestFeatureDifferentThanServer(t *testing.T) {
 	// mock a scenario where the state of the database is different than the feature variable
 	client := &MockClient{}
@@ -466,3 +828,225 @@ func TestFeatureDifferentThanServer(t *testing.T) {
 	assert.NoError(t, err)
 	assert.True(t, active)
 }
+
+func TestSubscribe(t *testing.T) {
+	store := NewMemoryStore()
+	other := NewManagerWithStore(store, mockKeyPrefix, false)
+	manager := NewManagerWithStore(store, mockKeyPrefix, false, WithCache(time.Hour, time.Hour))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	assert.NoError(t, manager.Subscribe(ctx))
+
+	f := NewFeature("example")
+
+	active, err := manager.IsActive(f)
+	assert.NoError(t, err)
+	assert.False(t, active)
+
+	// changes made elsewhere are seen without waiting for the ttl
+	assert.NoError(t, other.Activate(NewFeature("example")))
+
+	assert.Eventually(t, func() bool {
+		active, err := manager.IsActive(f)
+		return err == nil && active
+	}, time.Second, time.Millisecond)
+
+	// redis clients without pub/sub
+	manager = NewManager(&MockClient{}, mockKeyPrefix, false)
+	assert.EqualError(t, manager.Subscribe(ctx), "redis client doesn't support subscribing")
+}
+
+// segmentSubscribeFailingStore is a MemoryStore failing to subscribe to the segment changes
+type segmentSubscribeFailingStore struct {
+	*MemoryStore
+	messages <-chan string // the feature changes subscribed to
+}
+
+func (s *segmentSubscribeFailingStore) Subscribe(ctx context.Context, channel string) (<-chan string, error) {
+	if channel == mockKeyPrefix+":segment-changes" {
+		return nil, errors.New("mock error")
+	}
+
+	messages, err := s.MemoryStore.Subscribe(ctx, channel)
+	s.messages = messages
+
+	return messages, err
+}
+
+func TestSubscribeError(t *testing.T) {
+	store := &segmentSubscribeFailingStore{MemoryStore: NewMemoryStore()}
+	manager := NewManagerWithStore(store, mockKeyPrefix, false)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	assert.EqualError(t, manager.Subscribe(ctx), "mock error")
+
+	// the feature changes are unsubscribed from, without waiting for the context
+	assert.Eventually(t, func() bool {
+		_, ok := <-store.messages
+		return !ok
+	}, time.Second, time.Millisecond)
+}
+
+// failingNotifierStore is a MemoryStore failing to announce changes
+type failingNotifierStore struct {
+	*MemoryStore
+}
+
+func (s failingNotifierStore) Publish(ctx context.Context, channel string, message string) error {
+	return errors.New("mock error")
+}
+
+func TestNotifyError(t *testing.T) {
+	manager := NewManagerWithStore(failingNotifierStore{NewMemoryStore()}, mockKeyPrefix, false, WithCache(time.Hour, time.Hour))
+
+	// the change is made and cached even though it isn't announced
+	f := NewFeature("example")
+	err := manager.ActivateTeam(1, f)
+	assert.EqualError(t, err, `feature "example" was changed, but the change couldn't be announced: mock error`)
+	var notifyErr *NotifyError
+	assert.True(t, errors.As(err, &notifyErr))
+	assert.Equal(t, "example", notifyErr.Feature)
+
+	active, err := manager.IsTeamActive(1, f)
+	assert.NoError(t, err)
+	assert.True(t, active)
+
+	stored, err := manager.Get(f)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{1}, stored.TeamIDs())
+
+	deleted, err := manager.Delete(f)
+	assert.True(t, deleted)
+	assert.IsType(t, &NotifyError{}, err)
+
+	err = manager.AddSegmentTeams("beta", 1)
+	assert.EqualError(t, err, `segment "beta" was changed, but the change couldn't be announced: mock error`)
+
+	segment, err := manager.Segment("beta")
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{1}, segment.TeamIDs())
+
+	deleted, err = manager.DeleteSegment("beta")
+	assert.True(t, deleted)
+	assert.IsType(t, &NotifyError{}, err)
+}
+
+func TestFallback(t *testing.T) {
+	store := &failingStore{Store: NewMemoryStore()}
+	other := NewManagerWithStore(store, mockKeyPrefix, false)
+	assert.NoError(t, other.Activate(NewFeature("example1")))
+
+	features := []*Feature{NewFeature("example1"), NewFeature("example2")}
+
+	// error
+	manager := NewManagerWithStore(store, mockKeyPrefix, false)
+	store.fail = true
+	_, err := manager.IsActive(features[0])
+	assert.EqualError(t, err, "mock error")
+	_, err = manager.IsActiveMulti(features...)
+	assert.EqualError(t, err, "mock error")
+
+	// last known
+	manager = NewManagerWithStore(store, mockKeyPrefix, false, WithFallback(FallbackLastKnown))
+	store.fail = false
+	active, err := manager.IsActive(features[0])
+	assert.NoError(t, err)
+	assert.True(t, active)
+
+	store.fail = true
+	results, err := manager.IsActiveMulti(features...)
+	assert.NoError(t, err)
+	assert.Equal(t, []bool{true, false}, results)
+
+	// default
+	manager = NewManagerWithStore(store, mockKeyPrefix, false, WithFallback(FallbackDefault))
+	active, err = manager.IsTeamActive(1, features[0])
+	assert.NoError(t, err)
+	assert.False(t, active)
+
+	// closed
+	manager = NewManagerWithStore(store, mockKeyPrefix, false, WithFallback(FallbackClosed))
+	results, err = manager.IsTeamActiveMulti(1, features...)
+	assert.NoError(t, err)
+	assert.Equal(t, []bool{false, false}, results)
+}
+
+func TestDefaultActive(t *testing.T) {
+	store := &failingStore{Store: NewMemoryStore()}
+	manager := NewManagerWithStore(store, mockKeyPrefix, false)
+
+	f := NewFeature("example", WithDefault(true))
+
+	// feature not in the store is in its default state
+	active, err := manager.IsActive(f)
+	assert.NoError(t, err)
+	assert.True(t, active)
+
+	results, err := manager.IsTeamActiveMulti(1, f, NewFeature("other"))
+	assert.NoError(t, err)
+	assert.Equal(t, []bool{true, false}, results)
+
+	// changes start from the default state
+	assert.NoError(t, manager.ActivateTeam(1, f))
+
+	active, err = manager.IsActive(f)
+	assert.NoError(t, err)
+	assert.True(t, active)
+
+	// persisted state overrides the default
+	assert.NoError(t, manager.Deactivate(f))
+
+	active, err = manager.IsTeamActive(1, f)
+	assert.NoError(t, err)
+	assert.False(t, active)
+
+	// served when the store can't be reached
+	store.fail = true
+	manager = NewManagerWithStore(store, mockKeyPrefix, false, WithFallback(FallbackDefault))
+	active, err = manager.IsActive(f)
+	assert.NoError(t, err)
+	assert.True(t, active)
+
+	manager = NewManagerWithStore(store, mockKeyPrefix, false, WithFallback(FallbackClosed))
+	active, err = manager.IsActive(f)
+	assert.NoError(t, err)
+	assert.False(t, active)
+}
+
+func TestContext(t *testing.T) {
+	client := &MockClient{}
+	manager := NewManager(client, mockKeyPrefix, false)
+
+	f := NewFeature("example")
+
+	// methods succeed with a live context
+	ctx := context.Background()
+	assert.NoError(t, manager.ActivateTeamContext(ctx, 1, f))
+
+	client.feature.name = "example"
+	active, err := manager.IsTeamActiveContext(ctx, 1, f)
+	assert.NoError(t, err)
+	assert.True(t, active)
+
+	// methods fail once the context is done
+	ctx, cancel := context.WithTimeout(ctx, -time.Second)
+	defer cancel()
+
+	_, err = manager.IsActiveContext(ctx, f)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	_, err = manager.IsActiveMultiContext(ctx, f)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	_, err = manager.IsTeamActiveContext(ctx, 1, f)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	_, err = manager.IsTeamActiveMultiContext(ctx, 1, f)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Equal(t, context.DeadlineExceeded, manager.ActivateContext(ctx, f))
+	assert.Equal(t, context.DeadlineExceeded, manager.DeactivateContext(ctx, f))
+	assert.Equal(t, context.DeadlineExceeded, manager.ActivatePercentageContext(ctx, f, 50))
+	assert.Equal(t, context.DeadlineExceeded, manager.ActivateTeamContext(ctx, 1, f))
+	assert.Equal(t, context.DeadlineExceeded, manager.DeactivateTeamContext(ctx, 1, f))
+	_, err = manager.DeleteContext(ctx, f)
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
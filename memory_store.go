@@ -0,0 +1,187 @@
+package rollout
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// MemoryStore is a concurrent-safe Store, Notifier and Journal keeping the features in memory, useful for tests and local development
+type MemoryStore struct {
+	mu   sync.RWMutex
+	data map[string][]byte
+	logs map[string][][]byte
+
+	subscribersMu sync.RWMutex
+	subscribers   map[string][]*memorySubscriber
+}
+
+type memorySubscriber struct {
+	ctx      context.Context
+	messages chan string
+}
+
+// NewMemoryStore constructs a new, empty MemoryStore instance
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		data:        make(map[string][]byte),
+		logs:        make(map[string][][]byte),
+		subscribers: make(map[string][]*memorySubscriber),
+	}
+}
+
+// Get implements Store
+func (s *MemoryStore) Get(ctx context.Context, key string) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return clone(s.data[key]), nil
+}
+
+// MGet implements Store
+func (s *MemoryStore) MGet(ctx context.Context, keys ...string) ([][]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	results := make([][]byte, len(keys))
+	for i, key := range keys {
+		results[i] = clone(s.data[key])
+	}
+
+	return results, nil
+}
+
+// CompareAndSet implements Store
+func (s *MemoryStore) CompareAndSet(ctx context.Context, key string, old, value []byte) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, exists := s.data[key]
+	if exists != (old != nil) || !bytes.Equal(current, old) {
+		return false, nil
+	}
+
+	s.data[key] = clone(value)
+
+	return true, nil
+}
+
+// Delete implements Store
+func (s *MemoryStore) Delete(ctx context.Context, key string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, exists := s.data[key]
+	delete(s.data, key)
+
+	return exists, nil
+}
+
+// List implements Store
+func (s *MemoryStore) List(ctx context.Context, prefix string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var keys []string
+	for key := range s.data {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	return keys, nil
+}
+
+// Append implements Journal
+func (s *MemoryStore) Append(ctx context.Context, key string, entry []byte, max int) error {
+	if max < 0 {
+		return ErrNegativeJournalMax
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	log := append(s.logs[key], clone(entry))
+	if max > 0 && len(log) > max {
+		log = append([][]byte(nil), log[len(log)-max:]...)
+	}
+	s.logs[key] = log
+
+	return nil
+}
+
+// Entries implements Journal
+func (s *MemoryStore) Entries(ctx context.Context, key string, count int) ([][]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	log := s.logs[key]
+	if count > 0 && len(log) > count {
+		log = log[len(log)-count:]
+	}
+
+	entries := make([][]byte, len(log))
+	for i, entry := range log {
+		entries[i] = clone(entry)
+	}
+
+	return entries, nil
+}
+
+// Publish implements Notifier, waiting for every subscriber to receive the message
+func (s *MemoryStore) Publish(ctx context.Context, channel string, message string) error {
+	// subscribers are only removed once no message is being delivered to them
+	s.subscribersMu.RLock()
+	defer s.subscribersMu.RUnlock()
+
+	for _, sub := range s.subscribers[channel] {
+		select {
+		case sub.messages <- message:
+		case <-sub.ctx.Done():
+			// subscriber is going away
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return nil
+}
+
+// Subscribe implements Notifier
+func (s *MemoryStore) Subscribe(ctx context.Context, channel string) (<-chan string, error) {
+	sub := &memorySubscriber{ctx: ctx, messages: make(chan string)}
+
+	s.subscribersMu.Lock()
+	s.subscribers[channel] = append(s.subscribers[channel], sub)
+	s.subscribersMu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+
+		s.subscribersMu.Lock()
+		defer s.subscribersMu.Unlock()
+
+		subscribers := s.subscribers[channel]
+		for i := range subscribers {
+			if subscribers[i] == sub {
+				s.subscribers[channel] = append(subscribers[:i:i], subscribers[i+1:]...)
+				break
+			}
+		}
+		close(sub.messages)
+	}()
+
+	return sub.messages, nil
+}
+
+// clone copies the value so callers can't modify the stored data, preserving nil
+func clone(value []byte) []byte {
+	if value == nil {
+		return nil
+	}
+
+	return append(make([]byte, 0, len(value)), value...)
+}
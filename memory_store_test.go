@@ -0,0 +1,187 @@
+package rollout
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+
+	// key doesn't exist
+	value, err := store.Get(ctx, "rollout:example1")
+	assert.NoError(t, err)
+	assert.Nil(t, value)
+
+	// set only when the key doesn't exist
+	swapped, err := store.CompareAndSet(ctx, "rollout:example1", nil, []byte("a"))
+	assert.NoError(t, err)
+	assert.True(t, swapped)
+
+	swapped, err = store.CompareAndSet(ctx, "rollout:example1", nil, []byte("b"))
+	assert.NoError(t, err)
+	assert.False(t, swapped)
+
+	// set only when the key holds the old value
+	swapped, err = store.CompareAndSet(ctx, "rollout:example1", []byte("b"), []byte("c"))
+	assert.NoError(t, err)
+	assert.False(t, swapped)
+
+	swapped, err = store.CompareAndSet(ctx, "rollout:example1", []byte("a"), []byte("c"))
+	assert.NoError(t, err)
+	assert.True(t, swapped)
+
+	value, err = store.Get(ctx, "rollout:example1")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("c"), value)
+
+	// returned values can't modify the store
+	value[0] = 'x'
+	value, err = store.Get(ctx, "rollout:example1")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("c"), value)
+
+	// multiple keys
+	_, err = store.CompareAndSet(ctx, "rollout:example2", nil, []byte("d"))
+	assert.NoError(t, err)
+	_, err = store.CompareAndSet(ctx, "other:example3", nil, []byte("e"))
+	assert.NoError(t, err)
+
+	values, err := store.MGet(ctx, "rollout:example1", "rollout:missing", "rollout:example2")
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("c"), nil, []byte("d")}, values)
+
+	keys, err := store.List(ctx, "rollout:")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"rollout:example1", "rollout:example2"}, keys)
+
+	// delete
+	deleted, err := store.Delete(ctx, "rollout:example1")
+	assert.NoError(t, err)
+	assert.True(t, deleted)
+
+	deleted, err = store.Delete(ctx, "rollout:example1")
+	assert.NoError(t, err)
+	assert.False(t, deleted)
+
+	keys, err = store.List(ctx, "rollout:")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"rollout:example2"}, keys)
+
+	// logs, trimmed to their most recent entries
+	for _, entry := range []string{"a", "b", "c"} {
+		assert.NoError(t, store.Append(ctx, "rollout-history:example1", []byte(entry), 2))
+	}
+
+	entries, err := store.Entries(ctx, "rollout-history:example1", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("b"), []byte("c")}, entries)
+
+	entries, err = store.Entries(ctx, "rollout-history:example1", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("c")}, entries)
+
+	entries, err = store.Entries(ctx, "rollout-history:missing", 0)
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+
+	// logs without a max keep every entry
+	for _, entry := range []string{"a", "b", "c"} {
+		assert.NoError(t, store.Append(ctx, "rollout-history:example2", []byte(entry), 0))
+	}
+
+	entries, err = store.Entries(ctx, "rollout-history:example2", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("a"), []byte("b"), []byte("c")}, entries)
+
+	assert.Equal(t, ErrNegativeJournalMax, store.Append(ctx, "rollout-history:example2", []byte("d"), -1))
+}
+
+func TestMemoryStoreManager(t *testing.T) {
+	manager := NewManagerWithStore(NewMemoryStore(), mockKeyPrefix, false)
+
+	f := NewFeature("example")
+
+	// feature not in the store
+	active, err := manager.IsTeamActive(1, f)
+	assert.NoError(t, err)
+	assert.False(t, active)
+
+	// activate a team
+	err = manager.ActivateTeam(1, NewFeature("example"))
+	assert.NoError(t, err)
+
+	active, err = manager.IsTeamActive(1, f)
+	assert.NoError(t, err)
+	assert.True(t, active)
+
+	active, err = manager.IsActive(f)
+	assert.NoError(t, err)
+	assert.False(t, active)
+
+	// activate globally
+	err = manager.Activate(NewFeature("example"))
+	assert.NoError(t, err)
+
+	results, err := manager.IsTeamActiveMulti(2, f, NewFeature("other"))
+	assert.NoError(t, err)
+	assert.Equal(t, []bool{true, false}, results)
+
+	// delete
+	deleted, err := manager.Delete(NewFeature("example"))
+	assert.NoError(t, err)
+	assert.True(t, deleted)
+
+	active, err = manager.IsTeamActive(1, f)
+	assert.NoError(t, err)
+	assert.False(t, active)
+}
+
+func TestMemoryStoreConcurrentUpdates(t *testing.T) {
+	manager := NewManagerWithStore(NewMemoryStore(), mockKeyPrefix, false)
+
+	// concurrently activate teams, each with their own feature instance
+	var wg sync.WaitGroup
+	for teamID := int64(1); teamID <= 5; teamID++ {
+		wg.Add(1)
+		go func(teamID int64) {
+			defer wg.Done()
+			assert.NoError(t, manager.ActivateTeam(teamID, NewFeature("example")))
+		}(teamID)
+	}
+	wg.Wait()
+
+	f := NewFeature("example")
+	for teamID := int64(1); teamID <= 5; teamID++ {
+		active, err := manager.IsTeamActive(teamID, f)
+		assert.NoError(t, err)
+		assert.True(t, active)
+	}
+}
+
+func TestMemoryStorePubSub(t *testing.T) {
+	store := NewMemoryStore()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	messages, err := store.Subscribe(ctx, "rollout:changes")
+	assert.NoError(t, err)
+
+	go func() {
+		assert.NoError(t, store.Publish(context.Background(), "rollout:changes", "example"))
+		assert.NoError(t, store.Publish(context.Background(), "other:changes", "other"))
+		assert.NoError(t, store.Publish(context.Background(), "rollout:changes", "example2"))
+	}()
+
+	assert.Equal(t, "example", <-messages)
+	assert.Equal(t, "example2", <-messages)
+
+	// unsubscribed once the context is cancelled
+	cancel()
+	_, ok := <-messages
+	assert.False(t, ok)
+	assert.NoError(t, store.Publish(context.Background(), "rollout:changes", "example"))
+}
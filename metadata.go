@@ -0,0 +1,219 @@
+package rollout
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v4"
+)
+
+// Metadata describes a feature: why it exists, who owns it and when it was last changed, and by whom
+type Metadata struct {
+	Description string
+	Owner       string   // the team owning the feature
+	Tags        []string // sorted
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	UpdatedBy   string // the author of the last change, empty when unknown
+}
+
+// EncodeMsgpack implements msgpack.CustomEncoder
+func (md Metadata) EncodeMsgpack(enc *msgpack.Encoder) error {
+	return encodeTuple(enc, md.Description, md.Owner, md.Tags, unixNano(md.CreatedAt), unixNano(md.UpdatedAt), md.UpdatedBy)
+}
+
+// DecodeMsgpack implements msgpack.CustomDecoder
+func (md *Metadata) DecodeMsgpack(dec *msgpack.Decoder) error {
+	var createdAt, updatedAt int64
+	if err := decodeTuple(dec, &md.Description, &md.Owner, &md.Tags, &createdAt, &updatedAt, &md.UpdatedBy); err != nil {
+		return err
+	}
+	md.CreatedAt = fromUnixNano(createdAt)
+	md.UpdatedAt = fromUnixNano(updatedAt)
+
+	return nil
+}
+
+// HasTag returns whether the feature is tagged with the tag
+func (md Metadata) HasTag(tag string) bool {
+	i := sort.SearchStrings(md.Tags, tag)
+	return i < len(md.Tags) && md.Tags[i] == tag
+}
+
+// isZero returns whether nothing describes the feature, e.g. because it hasn't been changed since written by older versions
+func (md Metadata) isZero() bool {
+	return md.Description == "" && md.Owner == "" && len(md.Tags) == 0 &&
+		md.CreatedAt.IsZero() && md.UpdatedAt.IsZero() && md.UpdatedBy == ""
+}
+
+type authorKey struct{}
+
+// ContextWithAuthor returns a copy of the context recording the author of the changes made with it,
+// e.g. the name of the user running the CLI, in the metadata of the changed features
+func ContextWithAuthor(ctx context.Context, author string) context.Context {
+	return context.WithValue(ctx, authorKey{}, author)
+}
+
+// authorFromContext returns the author of the changes made with the context, empty when unknown
+func authorFromContext(ctx context.Context) string {
+	author, _ := ctx.Value(authorKey{}).(string)
+	return author
+}
+
+// touch records the change to the feature made by the author at the time
+func (f *Feature) touch(author string, now time.Time) {
+	if f.metadata.CreatedAt.IsZero() {
+		// features written by older versions are created by their first change since
+		f.metadata.CreatedAt = now
+	}
+
+	f.metadata.UpdatedAt = now
+	f.metadata.UpdatedBy = author
+}
+
+func (f *Feature) addTags(tags ...string) {
+	for _, tag := range tags {
+		if !f.metadata.HasTag(tag) {
+			f.metadata.Tags = append(f.metadata.Tags, tag)
+			sort.Strings(f.metadata.Tags)
+		}
+	}
+}
+
+func (f *Feature) removeTags(tags ...string) {
+	kept := make([]string, 0, len(f.metadata.Tags))
+	for _, tag := range f.metadata.Tags {
+		removed := false
+		for _, t := range tags {
+			removed = removed || t == tag
+		}
+		if !removed {
+			kept = append(kept, tag)
+		}
+	}
+
+	if len(kept) == 0 {
+		kept = nil
+	}
+	f.metadata.Tags = kept
+}
+
+// Metadata returns the description, owner, tags and timestamps of the feature
+func (m *Manager) Metadata(feature *Feature) (Metadata, error) {
+	return m.MetadataContext(context.Background(), feature)
+}
+
+// MetadataContext returns the description, owner, tags and timestamps of the feature, bounded by the context
+func (m *Manager) MetadataContext(ctx context.Context, feature *Feature) (Metadata, error) {
+	state, err := m.load(ctx, feature)
+	if err != nil {
+		return Metadata{}, err
+	}
+
+	return state.Metadata(), nil
+}
+
+// SetDescription sets why the feature exists
+func (m *Manager) SetDescription(feature *Feature, description string) error {
+	return m.SetDescriptionContext(context.Background(), feature, description)
+}
+
+// SetDescriptionContext sets why the feature exists, bounded by the context
+func (m *Manager) SetDescriptionContext(ctx context.Context, feature *Feature, description string) error {
+	return m.update(ctx, feature, operation("set-description", description), func(f *Feature) {
+		f.metadata.Description = description
+	})
+}
+
+// SetOwner sets the team owning the feature
+func (m *Manager) SetOwner(feature *Feature, owner string) error {
+	return m.SetOwnerContext(context.Background(), feature, owner)
+}
+
+// SetOwnerContext sets the team owning the feature, bounded by the context
+func (m *Manager) SetOwnerContext(ctx context.Context, feature *Feature, owner string) error {
+	return m.update(ctx, feature, operation("set-owner", owner), func(f *Feature) {
+		f.metadata.Owner = owner
+	})
+}
+
+// AddTags tags the feature with the tags
+func (m *Manager) AddTags(feature *Feature, tags ...string) error {
+	return m.AddTagsContext(context.Background(), feature, tags...)
+}
+
+// AddTagsContext tags the feature with the tags, bounded by the context
+func (m *Manager) AddTagsContext(ctx context.Context, feature *Feature, tags ...string) error {
+	return m.update(ctx, feature, operation("add-tags", strings.Join(tags, " ")), func(f *Feature) {
+		f.addTags(tags...)
+	})
+}
+
+// RemoveTags removes the tags from the feature
+func (m *Manager) RemoveTags(feature *Feature, tags ...string) error {
+	return m.RemoveTagsContext(context.Background(), feature, tags...)
+}
+
+// RemoveTagsContext removes the tags from the feature, bounded by the context
+func (m *Manager) RemoveTagsContext(ctx context.Context, feature *Feature, tags ...string) error {
+	return m.update(ctx, feature, operation("remove-tags", strings.Join(tags, " ")), func(f *Feature) {
+		f.removeTags(tags...)
+	})
+}
+
+// MetadataChange is a set of edits to the metadata of a feature, made together in a single change
+type MetadataChange struct {
+	Description *string // the new description, unchanged when nil
+	Owner       *string // the new owner, unchanged when nil
+	AddTags     []string
+	RemoveTags  []string
+}
+
+// operation describes the edits the change makes, in the order they're made
+func (c MetadataChange) operation() string {
+	var edits []string
+	if c.Description != nil {
+		edits = append(edits, operation("set-description", *c.Description))
+	}
+	if c.Owner != nil {
+		edits = append(edits, operation("set-owner", *c.Owner))
+	}
+	if len(c.AddTags) > 0 {
+		edits = append(edits, operation("add-tags", strings.Join(c.AddTags, " ")))
+	}
+	if len(c.RemoveTags) > 0 {
+		edits = append(edits, operation("remove-tags", strings.Join(c.RemoveTags, " ")))
+	}
+
+	return strings.Join(edits, "; ")
+}
+
+// apply makes the edits to the feature, adding tags before removing them
+func (c MetadataChange) apply(f *Feature) {
+	if c.Description != nil {
+		f.metadata.Description = *c.Description
+	}
+	if c.Owner != nil {
+		f.metadata.Owner = *c.Owner
+	}
+	f.addTags(c.AddTags...)
+	f.removeTags(c.RemoveTags...)
+}
+
+// ChangeMetadata makes every edit of the change to the metadata of the feature at once
+func (m *Manager) ChangeMetadata(feature *Feature, change MetadataChange) error {
+	return m.ChangeMetadataContext(context.Background(), feature, change)
+}
+
+// ChangeMetadataContext makes every edit of the change to the metadata of the feature at once, bounded by the context.
+// Changes without any edit leave the feature untouched.
+func (m *Manager) ChangeMetadataContext(ctx context.Context, feature *Feature, change MetadataChange) error {
+	op := change.operation()
+	if op == "" {
+		return nil
+	}
+
+	return m.update(ctx, feature, op, change.apply)
+}
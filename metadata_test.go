@@ -0,0 +1,94 @@
+package rollout
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFeatureMetadata(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	f := NewFeature("example")
+	f.metadata.Description = "New checkout flow"
+	f.addTags("checkout", "beta", "checkout")
+	assert.Equal(t, []string{"beta", "checkout"}, f.Metadata().Tags)
+	assert.True(t, f.Metadata().HasTag("beta"))
+	assert.False(t, f.Metadata().HasTag("alpha"))
+
+	f.touch("alice", now)
+	f.touch("bob", now.Add(time.Hour))
+
+	md := f.Metadata()
+	assert.True(t, md.CreatedAt.Equal(now))
+	assert.True(t, md.UpdatedAt.Equal(now.Add(time.Hour)))
+	assert.Equal(t, "bob", md.UpdatedBy)
+
+	f.removeTags("checkout", "alpha")
+	assert.Equal(t, []string{"beta"}, f.Metadata().Tags)
+	f.removeTags("beta")
+	assert.Nil(t, f.Metadata().Tags)
+}
+
+func TestManagerMetadata(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	manager := NewManagerWithStore(NewMemoryStore(), mockKeyPrefix, true)
+	manager.now = func() time.Time { return now }
+
+	f := NewFeature("example")
+	ctx := ContextWithAuthor(context.Background(), "alice")
+	assert.NoError(t, manager.SetDescriptionContext(ctx, f, "New checkout flow"))
+	assert.NoError(t, manager.SetOwnerContext(ctx, f, "payments"))
+	assert.NoError(t, manager.AddTagsContext(ctx, f, "checkout", "q3"))
+
+	md, err := manager.Metadata(f)
+	assert.NoError(t, err)
+	assert.Equal(t, "New checkout flow", md.Description)
+	assert.Equal(t, "payments", md.Owner)
+	assert.Equal(t, []string{"checkout", "q3"}, md.Tags)
+	assert.True(t, md.CreatedAt.Equal(now))
+	assert.Equal(t, "alice", md.UpdatedBy)
+
+	// every change is recorded, by unknown authors without a context recording them
+	now = now.Add(time.Hour)
+	assert.NoError(t, manager.RemoveTags(f, "q3"))
+	assert.NoError(t, manager.ActivateTeam(1, f))
+
+	md, err = manager.Metadata(f)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"checkout"}, md.Tags)
+	assert.True(t, md.CreatedAt.Equal(now.Add(-time.Hour)))
+	assert.True(t, md.UpdatedAt.Equal(now))
+	assert.Empty(t, md.UpdatedBy)
+}
+
+func TestManagerChangeMetadata(t *testing.T) {
+	manager := NewManagerWithStore(NewMemoryStore(), mockKeyPrefix, true, WithHistory(DefaultHistoryLimit))
+
+	f := NewFeature("example")
+	assert.NoError(t, manager.AddTags(f, "q3"))
+
+	description, owner := "New checkout flow", ""
+	assert.NoError(t, manager.ChangeMetadata(f, MetadataChange{
+		Description: &description,
+		Owner:       &owner,
+		AddTags:     []string{"checkout"},
+		RemoveTags:  []string{"q3"},
+	}))
+	assert.NoError(t, manager.ChangeMetadata(f, MetadataChange{}))
+
+	md, err := manager.Metadata(f)
+	assert.NoError(t, err)
+	assert.Equal(t, "New checkout flow", md.Description)
+	assert.Empty(t, md.Owner)
+	assert.Equal(t, []string{"checkout"}, md.Tags)
+
+	// every edit is made in a single change
+	history, err := manager.History(f)
+	assert.NoError(t, err)
+	assert.Len(t, history, 2)
+	assert.Equal(t, int64(2), history[1].Version)
+	assert.Equal(t, "set-description New checkout flow; set-owner; add-tags checkout; remove-tags q3", history[1].Operation)
+}
@@ -0,0 +1,73 @@
+package rollout
+
+import "time"
+
+// Option configures optional behaviour of a Manager
+type Option func(m *Manager)
+
+// WithCache enables caching fetched features in memory, so most evaluations don't read from the store.
+// Features are served from memory for the ttl, after which they're served for up to maxStaleness while
+// being refreshed in the background. Changes made through the Manager update the cache immediately.
+func WithCache(ttl, maxStaleness time.Duration) Option {
+	return func(m *Manager) {
+		m.cache = newCache(ttl, maxStaleness)
+	}
+}
+
+// Fallback determines what evaluations return when the store can't be reached
+type Fallback int
+
+const (
+	// FallbackError returns the error to the caller
+	FallbackError Fallback = iota
+
+	// FallbackLastKnown serves the last state fetched for the feature, or its declared default when never fetched
+	FallbackLastKnown
+
+	// FallbackDefault serves the declared default of the feature
+	FallbackDefault
+
+	// FallbackClosed treats the feature as inactive
+	FallbackClosed
+)
+
+// WithFallback sets what evaluations return when the store can't be reached, instead of returning the error
+func WithFallback(fallback Fallback) Option {
+	return func(m *Manager) {
+		m.fallback = fallback
+	}
+}
+
+// WithCircuitBreaker stops calling the store for the cooldown once threshold consecutive calls fail,
+// returning ErrCircuitOpen instead, so an unavailable store isn't hammered on every evaluation
+func WithCircuitBreaker(threshold int, cooldown time.Duration) Option {
+	if threshold < 1 {
+		threshold = 1
+	}
+
+	return func(m *Manager) {
+		m.breaker = &breaker{
+			threshold: threshold,
+			cooldown:  cooldown,
+			now:       func() time.Time { return m.now() },
+		}
+	}
+}
+
+// WithHistory records the changes made through the Manager in a history per feature, keeping the most recent
+// limit changes of each, e.g. DefaultHistoryLimit. The history is kept by stores implementing Journal, which the
+// redis stores do in streams, needing redis 5 or later.
+func WithHistory(limit int) Option {
+	return func(m *Manager) {
+		m.historyLimit = limit
+	}
+}
+
+// WithVersionedRecords writes the features changed through the Manager as versioned maps of their fields, rather than
+// the legacy records every version reads. Versions before the map records can't read them, so only enable it once
+// every service reading the features is upgraded. Features rewritten by Migrate are written as maps regardless.
+func WithVersionedRecords() Option {
+	return func(m *Manager) {
+		m.versionedRecords = true
+	}
+}
@@ -0,0 +1,269 @@
+package rollout
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/vmihailenco/msgpack/v4"
+)
+
+// PayloadType names the type of the value of a payload
+type PayloadType string
+
+const (
+	// PayloadString is the type of payloads holding a string
+	PayloadString PayloadType = "string"
+
+	// PayloadNumber is the type of payloads holding a number
+	PayloadNumber PayloadType = "number"
+
+	// PayloadJSON is the type of payloads holding a JSON document
+	PayloadJSON PayloadType = "json"
+)
+
+// Payload is a typed value, e.g. a rate limit or UI copy, served along with a feature or one of its variants
+type Payload struct {
+	Type  PayloadType
+	Value string // the string, the formatted number, or the JSON document
+}
+
+// NewStringPayload constructs a payload holding a string
+func NewStringPayload(value string) Payload {
+	return Payload{Type: PayloadString, Value: value}
+}
+
+// NewNumberPayload constructs a payload holding a number
+func NewNumberPayload(value float64) Payload {
+	return Payload{Type: PayloadNumber, Value: strconv.FormatFloat(value, 'g', -1, 64)}
+}
+
+// NewJSONPayload constructs a payload holding the value marshaled to JSON
+func NewJSONPayload(value interface{}) (Payload, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return Payload{}, err
+	}
+
+	return Payload{Type: PayloadJSON, Value: string(data)}, nil
+}
+
+// ParsePayload parses the value of a payload of the given type
+func ParsePayload(typ PayloadType, value string) (Payload, error) {
+	payload := Payload{Type: typ, Value: value}
+	if err := payload.validate(); err != nil {
+		return Payload{}, err
+	}
+
+	return payload, nil
+}
+
+// EncodeMsgpack implements msgpack.CustomEncoder
+func (p Payload) EncodeMsgpack(enc *msgpack.Encoder) error {
+	return encodeTuple(enc, p.Type, p.Value)
+}
+
+// DecodeMsgpack implements msgpack.CustomDecoder
+func (p *Payload) DecodeMsgpack(dec *msgpack.Decoder) error {
+	return decodeTuple(dec, &p.Type, &p.Value)
+}
+
+// validate returns an error when the value isn't of the type of the payload
+func (p Payload) validate() error {
+	switch p.Type {
+	case PayloadString:
+		return nil
+	case PayloadNumber:
+		if _, err := strconv.ParseFloat(p.Value, 64); err != nil {
+			return fmt.Errorf("payload value %q isn't a number", p.Value)
+		}
+		return nil
+	case PayloadJSON:
+		if !json.Valid([]byte(p.Value)) {
+			return fmt.Errorf("payload value %q isn't valid JSON", p.Value)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown payload type %q", p.Type)
+	}
+}
+
+// StringOr returns the string held by the payload, the default when it doesn't hold a string
+func (p Payload) StringOr(defaultValue string) string {
+	if p.Type != PayloadString {
+		return defaultValue
+	}
+
+	return p.Value
+}
+
+// NumberOr returns the number held by the payload, the default when it doesn't hold a number
+func (p Payload) NumberOr(defaultValue float64) float64 {
+	if p.Type != PayloadNumber {
+		return defaultValue
+	}
+
+	n, err := strconv.ParseFloat(p.Value, 64)
+	if err != nil {
+		return defaultValue
+	}
+
+	return n
+}
+
+// DecodeJSON unmarshals the JSON document held by the payload into v, reporting whether it holds one.
+// v is left untouched, e.g. holding a default, when the payload doesn't hold a JSON document.
+func (p Payload) DecodeJSON(v interface{}) (bool, error) {
+	if p.Type != PayloadJSON {
+		return false, nil
+	}
+
+	if err := json.Unmarshal([]byte(p.Value), v); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// setPayload sets the payload of the variant, the empty variant being the feature without variants
+func (f *Feature) setPayload(variant string, payload Payload) {
+	if f.payloads == nil {
+		f.payloads = make(map[string]Payload)
+	}
+
+	f.payloads[variant] = payload
+}
+
+func (f *Feature) removePayload(variant string) {
+	delete(f.payloads, variant)
+}
+
+// payload returns the payload served to the actor of the evaluation, which is the payload of the variant
+// assigned to it, falling back to the payload of the feature without variants. It's the zero payload
+// when the feature isn't active for the actor.
+func (f *Feature) payload(e evaluation) Payload {
+	if !f.evaluate(e) {
+		return Payload{}
+	}
+
+	if payload, ok := f.payloads[f.variant(e)]; ok {
+		return payload
+	}
+
+	return f.payloads[""]
+}
+
+// SetPayload sets the payload served along with the variant of the feature. The payload of the empty variant is
+// served when the feature has no variants, and when the assigned variant has no payload of its own.
+func (m *Manager) SetPayload(feature *Feature, variant string, payload Payload) error {
+	return m.SetPayloadContext(context.Background(), feature, variant, payload)
+}
+
+// SetPayloadContext sets the payload served along with the variant of the feature, bounded by the context
+func (m *Manager) SetPayloadContext(ctx context.Context, feature *Feature, variant string, payload Payload) error {
+	if err := payload.validate(); err != nil {
+		return err
+	}
+
+	return m.update(ctx, feature, operation("set-payload", variant), func(f *Feature) {
+		f.setPayload(variant, payload)
+	})
+}
+
+// RemovePayload removes the payload served along with the variant of the feature
+func (m *Manager) RemovePayload(feature *Feature, variant string) error {
+	return m.RemovePayloadContext(context.Background(), feature, variant)
+}
+
+// RemovePayloadContext removes the payload served along with the variant of the feature, bounded by the context
+func (m *Manager) RemovePayloadContext(ctx context.Context, feature *Feature, variant string) error {
+	return m.update(ctx, feature, operation("remove-payload", variant), func(f *Feature) {
+		f.removePayload(variant)
+	})
+}
+
+// TeamString returns the string payload of the feature for a team, the default when the feature
+// isn't active for the team or doesn't serve it a string
+func (m *Manager) TeamString(teamID int64, feature *Feature, defaultValue string) (string, error) {
+	return m.TeamStringContext(context.Background(), teamID, feature, defaultValue)
+}
+
+// TeamStringContext returns the string payload of the feature for a team, bounded by the context
+func (m *Manager) TeamStringContext(ctx context.Context, teamID int64, feature *Feature, defaultValue string) (string, error) {
+	payload, err := m.ActorPayloadWithAttributesContext(ctx, Team(teamID), nil, feature)
+	if err != nil {
+		return defaultValue, err
+	}
+
+	return payload.StringOr(defaultValue), nil
+}
+
+// TeamNumber returns the number payload of the feature for a team, the default when the feature
+// isn't active for the team or doesn't serve it a number
+func (m *Manager) TeamNumber(teamID int64, feature *Feature, defaultValue float64) (float64, error) {
+	return m.TeamNumberContext(context.Background(), teamID, feature, defaultValue)
+}
+
+// TeamNumberContext returns the number payload of the feature for a team, bounded by the context
+func (m *Manager) TeamNumberContext(ctx context.Context, teamID int64, feature *Feature, defaultValue float64) (float64, error) {
+	payload, err := m.ActorPayloadWithAttributesContext(ctx, Team(teamID), nil, feature)
+	if err != nil {
+		return defaultValue, err
+	}
+
+	return payload.NumberOr(defaultValue), nil
+}
+
+// TeamJSON unmarshals the JSON payload of the feature for a team into v, reporting whether the feature serves
+// the team one. v is left untouched, e.g. holding a default, when the feature isn't active for the team.
+func (m *Manager) TeamJSON(teamID int64, feature *Feature, v interface{}) (bool, error) {
+	return m.TeamJSONContext(context.Background(), teamID, feature, v)
+}
+
+// TeamJSONContext unmarshals the JSON payload of the feature for a team into v, bounded by the context
+func (m *Manager) TeamJSONContext(ctx context.Context, teamID int64, feature *Feature, v interface{}) (bool, error) {
+	payload, err := m.ActorPayloadWithAttributesContext(ctx, Team(teamID), nil, feature)
+	if err != nil {
+		return false, err
+	}
+
+	return payload.DecodeJSON(v)
+}
+
+// ActorPayload returns the payload of the feature for an actor, the zero payload when the feature
+// isn't active for the actor or doesn't serve it one
+func (m *Manager) ActorPayload(actor Actor, feature *Feature) (Payload, error) {
+	return m.ActorPayloadContext(context.Background(), actor, feature)
+}
+
+// ActorPayloadContext returns the payload of the feature for an actor, bounded by the context
+func (m *Manager) ActorPayloadContext(ctx context.Context, actor Actor, feature *Feature) (Payload, error) {
+	return m.ActorPayloadWithAttributesContext(ctx, actor, nil, feature)
+}
+
+// ActorPayloadWithAttributes returns the payload of the feature for an actor with the attributes,
+// which are matched against the targeting rules of the feature
+func (m *Manager) ActorPayloadWithAttributes(actor Actor, attributes Attributes, feature *Feature) (Payload, error) {
+	return m.ActorPayloadWithAttributesContext(context.Background(), actor, attributes, feature)
+}
+
+// ActorPayloadWithAttributesContext returns the payload of the feature for an actor with the attributes, bounded by the context
+func (m *Manager) ActorPayloadWithAttributesContext(ctx context.Context, actor Actor, attributes Attributes, feature *Feature) (Payload, error) {
+	state, err := m.load(ctx, feature)
+	if err != nil {
+		return Payload{}, err
+	}
+
+	segments, err := m.loadSegments(ctx, state)
+	if err != nil {
+		return Payload{}, err
+	}
+
+	e := m.evaluation(actor, attributes, segments)
+	if active, err := m.evaluate(ctx, state, e); err != nil || !active {
+		return Payload{}, err
+	}
+
+	return state.payload(e), nil
+}
@@ -0,0 +1,114 @@
+package rollout
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePayload(t *testing.T) {
+	p, err := ParsePayload(PayloadNumber, "1.5")
+	assert.NoError(t, err)
+	assert.Equal(t, NewNumberPayload(1.5), p)
+
+	p, err = ParsePayload(PayloadJSON, `{"limit":10}`)
+	assert.NoError(t, err)
+	assert.Equal(t, Payload{Type: PayloadJSON, Value: `{"limit":10}`}, p)
+
+	_, err = ParsePayload(PayloadNumber, "many")
+	assert.EqualError(t, err, `payload value "many" isn't a number`)
+
+	_, err = ParsePayload(PayloadJSON, "{")
+	assert.EqualError(t, err, `payload value "{" isn't valid JSON`)
+
+	_, err = ParsePayload("bool", "true")
+	assert.EqualError(t, err, `unknown payload type "bool"`)
+}
+
+func TestPayloadValues(t *testing.T) {
+	assert.Equal(t, "hello", NewStringPayload("hello").StringOr("default"))
+	assert.Equal(t, "default", NewNumberPayload(1).StringOr("default"))
+	assert.Equal(t, 2.5, NewNumberPayload(2.5).NumberOr(1))
+	assert.Equal(t, float64(1), Payload{}.NumberOr(1))
+
+	p, err := NewJSONPayload(map[string]int{"limit": 10})
+	assert.NoError(t, err)
+
+	var v struct{ Limit int }
+	ok, err := p.DecodeJSON(&v)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 10, v.Limit)
+
+	v.Limit = 5
+	ok, err = NewStringPayload("hello").DecodeJSON(&v)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, 5, v.Limit)
+}
+
+func TestFeaturePayload(t *testing.T) {
+	f := NewFeature("example")
+	f.setPayload("", NewStringPayload("default copy"))
+	f.setPayload("blue", NewStringPayload("blue copy"))
+
+	// no payload while the feature isn't active
+	assert.Equal(t, Payload{}, f.payload(evaluation{actor: Team(1)}))
+
+	f.activate()
+	assert.Equal(t, NewStringPayload("default copy"), f.payload(evaluation{actor: Team(1)}))
+
+	// the payload of the assigned variant, falling back to the payload of the feature
+	f.setVariant("blue", 1)
+	assert.Equal(t, NewStringPayload("blue copy"), f.payload(evaluation{actor: Team(1)}))
+
+	f.setVariant("green", 1)
+	f.removeVariant("blue")
+	assert.Equal(t, NewStringPayload("default copy"), f.payload(evaluation{actor: Team(1)}))
+	assert.Equal(t, map[string]Payload{"": NewStringPayload("default copy")}, f.Payloads())
+}
+
+func TestManagerPayload(t *testing.T) {
+	manager := NewManagerWithStore(NewMemoryStore(), mockKeyPrefix, true)
+
+	f := NewFeature("example")
+	assert.NoError(t, manager.SetPayload(f, "", NewNumberPayload(100)))
+	assert.EqualError(t, manager.SetPayload(f, "", Payload{Type: PayloadNumber, Value: "x"}), `payload value "x" isn't a number`)
+
+	limit, err := manager.TeamNumber(1, f, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(10), limit)
+
+	assert.NoError(t, manager.ActivateTeam(1, f))
+
+	limit, err = manager.TeamNumber(1, f, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(100), limit)
+
+	text, err := manager.TeamString(1, f, "default")
+	assert.NoError(t, err)
+	assert.Equal(t, "default", text)
+
+	settings, err := NewJSONPayload(map[string]bool{"compact": true})
+	assert.NoError(t, err)
+	assert.NoError(t, manager.SetPayload(f, "", settings))
+
+	var v struct{ Compact bool }
+	ok, err := manager.TeamJSON(1, f, &v)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.True(t, v.Compact)
+
+	assert.NoError(t, manager.SetVariant(f, "treatment", 1))
+	assert.NoError(t, manager.SetPayload(f, "treatment", NewStringPayload("treatment copy")))
+
+	payload, err := manager.ActorPayload(Team(1), f)
+	assert.NoError(t, err)
+	assert.Equal(t, NewStringPayload("treatment copy"), payload)
+
+	assert.NoError(t, manager.RemovePayload(f, "treatment"))
+
+	payload, err = manager.ActorPayload(Team(1), f)
+	assert.NoError(t, err)
+	assert.Equal(t, settings, payload)
+}
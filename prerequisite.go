@@ -0,0 +1,155 @@
+package rollout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// CycleError is returned when adding a prerequisite would make a feature depend on itself
+type CycleError struct {
+	Features []string // the names of the features forming the cycle, starting and ending with the same feature
+}
+
+func (e *CycleError) Error() string {
+	return fmt.Sprintf("prerequisites would form a cycle: %s", strings.Join(e.Features, " -> "))
+}
+
+func (f *Feature) addPrerequisite(name string) {
+	if f.prerequisites == nil {
+		f.prerequisites = make(stringSet)
+	}
+
+	f.prerequisites[name] = struct{}{}
+}
+
+func (f *Feature) removePrerequisite(name string) {
+	delete(f.prerequisites, name)
+}
+
+// prerequisitesActive returns whether every prerequisite of the feature is active, along with their own prerequisites,
+// for the actor of the evaluation, or globally when there's no evaluation. The path holds the names of the features
+// depending on the feature; prerequisites forming a cycle, which can only be written concurrently, are never active.
+func (m *Manager) prerequisitesActive(ctx context.Context, state *Feature, e *evaluation, path []string) (bool, error) {
+	if len(state.prerequisites) == 0 {
+		return true, nil
+	}
+
+	path = append(path, state.name)
+
+	features := make([]*Feature, 0, len(state.prerequisites))
+	for name := range state.prerequisites {
+		for _, dependent := range path {
+			if name == dependent {
+				return false, nil
+			}
+		}
+
+		features = append(features, NewFeature(name))
+	}
+
+	states, err := m.loadMulti(ctx, features...)
+	if err != nil {
+		return false, err
+	}
+
+	if e != nil {
+		segments, err := m.loadSegments(ctx, states...)
+		if err != nil {
+			return false, err
+		}
+
+		prerequisite := *e
+		prerequisite.segments = segments
+		e = &prerequisite
+	}
+
+	for _, prerequisite := range states {
+		if e == nil && !prerequisite.isActive() || e != nil && !prerequisite.evaluate(*e) {
+			return false, nil
+		}
+
+		active, err := m.prerequisitesActive(ctx, prerequisite, e, path)
+		if err != nil || !active {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// dependencyPath returns the names of the features from the feature to the dependency, following their prerequisites,
+// empty when the feature doesn't depend on the dependency. Features are read from the store, rather than the cache.
+func (m *Manager) dependencyPath(ctx context.Context, name string, dependency string, visited map[string]bool) ([]string, error) {
+	if name == dependency {
+		return []string{name}, nil
+	}
+	if visited[name] {
+		return nil, nil
+	}
+	visited[name] = true
+
+	feature := NewFeature(name)
+	data, err := m.store.Get(ctx, m.keyName(feature))
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := m.decode(feature, data)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, prerequisite := range state.Prerequisites() {
+		path, err := m.dependencyPath(ctx, prerequisite, dependency, visited)
+		if err != nil {
+			return nil, err
+		}
+		if path != nil {
+			return append([]string{name}, path...), nil
+		}
+	}
+
+	return nil, nil
+}
+
+// AddPrerequisite makes the feature only active for the teams and actors the prerequisite is active for, along with
+// its own prerequisites. Returns a *CycleError when the prerequisite depends on the feature.
+//
+// Prerequisites are known by name only, so one which was never written is inactive, whatever default it's declared
+// with where it's evaluated. Writing it through a Feature declaring its default, e.g. by blocking a team, persists it.
+func (m *Manager) AddPrerequisite(feature *Feature, prerequisite string) error {
+	return m.AddPrerequisiteContext(context.Background(), feature, prerequisite)
+}
+
+// AddPrerequisiteContext makes the feature depend on the prerequisite, bounded by the context
+func (m *Manager) AddPrerequisiteContext(ctx context.Context, feature *Feature, prerequisite string) error {
+	if prerequisite == "" {
+		return errors.New("prerequisite is missing the name")
+	}
+
+	path, err := m.dependencyPath(ctx, prerequisite, feature.Name(), make(map[string]bool))
+	if err != nil {
+		return err
+	}
+	if path != nil {
+		return &CycleError{Features: append([]string{feature.Name()}, path...)}
+	}
+
+	return m.update(ctx, feature, operation("add-prerequisite", prerequisite), func(f *Feature) {
+		f.addPrerequisite(prerequisite)
+	})
+}
+
+// RemovePrerequisite stops the feature depending on the prerequisite
+func (m *Manager) RemovePrerequisite(feature *Feature, prerequisite string) error {
+	return m.RemovePrerequisiteContext(context.Background(), feature, prerequisite)
+}
+
+// RemovePrerequisiteContext stops the feature depending on the prerequisite, bounded by the context
+func (m *Manager) RemovePrerequisiteContext(ctx context.Context, feature *Feature, prerequisite string) error {
+	return m.update(ctx, feature, operation("remove-prerequisite", prerequisite), func(f *Feature) {
+		f.removePrerequisite(prerequisite)
+	})
+}
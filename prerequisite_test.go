@@ -0,0 +1,117 @@
+package rollout
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFeaturePrerequisites(t *testing.T) {
+	f := NewFeature("new-editor-ai")
+	f.addPrerequisite("new-editor")
+	f.addPrerequisite("ai")
+	assert.Equal(t, []string{"ai", "new-editor"}, f.Prerequisites())
+
+	f.removePrerequisite("ai")
+	assert.Equal(t, []string{"new-editor"}, f.Prerequisites())
+}
+
+func TestManagerPrerequisites(t *testing.T) {
+	manager := NewManagerWithStore(NewMemoryStore(), mockKeyPrefix, true)
+
+	editor := NewFeature("new-editor")
+	ai := NewFeature("new-editor-ai")
+	assert.NoError(t, manager.AddPrerequisite(ai, "new-editor"))
+	assert.EqualError(t, manager.AddPrerequisite(ai, ""), "prerequisite is missing the name")
+	assert.NoError(t, manager.Activate(ai))
+	assert.NoError(t, manager.ActivateTeam(1, editor))
+
+	active, err := manager.IsTeamActive(1, ai)
+	assert.NoError(t, err)
+	assert.True(t, active)
+
+	active, err = manager.IsTeamActive(2, ai)
+	assert.NoError(t, err)
+	assert.False(t, active)
+
+	results, err := manager.IsTeamActiveMulti(2, ai, editor)
+	assert.NoError(t, err)
+	assert.Equal(t, []bool{false, false}, results)
+
+	active, err = manager.IsActive(ai)
+	assert.NoError(t, err)
+	assert.False(t, active)
+
+	// variants and payloads aren't served while a prerequisite is inactive
+	assert.NoError(t, manager.SetVariant(ai, "treatment", 1))
+	variant, err := manager.TeamVariant(2, ai)
+	assert.NoError(t, err)
+	assert.Empty(t, variant)
+
+	variant, err = manager.TeamVariant(1, ai)
+	assert.NoError(t, err)
+	assert.Equal(t, "treatment", variant)
+
+	// prerequisites of prerequisites must be active too, including segments they're active for
+	assert.NoError(t, manager.AddPrerequisite(editor, "editor-beta"))
+	assert.NoError(t, manager.AddSegmentTeams("beta", 1))
+	assert.NoError(t, manager.ActivateSegment("beta", NewFeature("editor-beta")))
+
+	active, err = manager.IsTeamActive(1, ai)
+	assert.NoError(t, err)
+	assert.True(t, active)
+
+	assert.NoError(t, manager.RemoveSegmentTeams("beta", 1))
+	active, err = manager.IsTeamActive(1, ai)
+	assert.NoError(t, err)
+	assert.False(t, active)
+
+	// cycles are detected on write
+	err = manager.AddPrerequisite(NewFeature("editor-beta"), "new-editor-ai")
+	assert.EqualError(t, err, "prerequisites would form a cycle: editor-beta -> new-editor-ai -> new-editor -> editor-beta")
+	assert.IsType(t, &CycleError{}, err)
+	assert.EqualError(t, manager.AddPrerequisite(ai, "new-editor-ai"), "prerequisites would form a cycle: new-editor-ai -> new-editor-ai")
+
+	assert.NoError(t, manager.RemovePrerequisite(editor, "editor-beta"))
+	active, err = manager.IsTeamActive(1, ai)
+	assert.NoError(t, err)
+	assert.True(t, active)
+
+	// cycles written concurrently are never active
+	assert.NoError(t, manager.update(context.Background(), editor, operation("add-prerequisite", "new-editor-ai"), func(f *Feature) {
+		f.addPrerequisite("new-editor-ai")
+	}))
+	active, err = manager.IsTeamActive(1, ai)
+	assert.NoError(t, err)
+	assert.False(t, active)
+}
+
+func TestManagerPrerequisiteDefault(t *testing.T) {
+	manager := NewManagerWithStore(NewMemoryStore(), mockKeyPrefix, true)
+
+	editor := NewFeature("new-editor", WithDefault(true))
+	ai := NewFeature("new-editor-ai")
+	assert.NoError(t, manager.AddPrerequisite(ai, "new-editor"))
+	assert.NoError(t, manager.Activate(ai))
+
+	// prerequisites which were never written are inactive, whatever default they're declared with
+	active, err := manager.IsTeamActive(1, editor)
+	assert.NoError(t, err)
+	assert.True(t, active)
+
+	active, err = manager.IsTeamActive(1, ai)
+	assert.NoError(t, err)
+	assert.False(t, active)
+
+	// writing the prerequisite persists its declared default
+	assert.NoError(t, manager.BlockTeam(2, editor))
+
+	active, err = manager.IsTeamActive(1, ai)
+	assert.NoError(t, err)
+	assert.True(t, active)
+
+	active, err = manager.IsTeamActive(2, ai)
+	assert.NoError(t, err)
+	assert.False(t, active)
+}
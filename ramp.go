@@ -0,0 +1,222 @@
+package rollout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v4"
+)
+
+// RampStep rolls a feature out a percentage for a duration
+type RampStep struct {
+	BasisPoints uint16 // the rollout of the step, in hundredths of a percent
+	Duration    time.Duration
+}
+
+// EncodeMsgpack implements msgpack.CustomEncoder
+func (s RampStep) EncodeMsgpack(enc *msgpack.Encoder) error {
+	return encodeTuple(enc, s.BasisPoints, int64(s.Duration))
+}
+
+// DecodeMsgpack implements msgpack.CustomDecoder
+func (s *RampStep) DecodeMsgpack(dec *msgpack.Decoder) error {
+	var duration int64
+	if err := decodeTuple(dec, &s.BasisPoints, &duration); err != nil {
+		return err
+	}
+	s.Duration = time.Duration(duration)
+
+	return nil
+}
+
+// Ramp progressively rolls a feature out, stepping through the percentages of its steps as their durations pass.
+// The rollout percentage is computed from the time the feature is evaluated, and stays at the percentage of
+// the last step once the ramp completes.
+type Ramp struct {
+	Steps     []RampStep
+	StartedAt time.Time
+	PausedAt  time.Time // zero unless the ramp is paused
+}
+
+// EncodeMsgpack implements msgpack.CustomEncoder
+func (r Ramp) EncodeMsgpack(enc *msgpack.Encoder) error {
+	return encodeTuple(enc, r.Steps, unixNano(r.StartedAt), unixNano(r.PausedAt))
+}
+
+// DecodeMsgpack implements msgpack.CustomDecoder
+func (r *Ramp) DecodeMsgpack(dec *msgpack.Decoder) error {
+	var startedAt, pausedAt int64
+	if err := decodeTuple(dec, &r.Steps, &startedAt, &pausedAt); err != nil {
+		return err
+	}
+	r.StartedAt = fromUnixNano(startedAt)
+	r.PausedAt = fromUnixNano(pausedAt)
+
+	return nil
+}
+
+// Paused returns whether the ramp is paused, holding the feature at the percentage of the current step
+func (r Ramp) Paused() bool {
+	return !r.PausedAt.IsZero()
+}
+
+// StepAt returns the index of the step the ramp is at at the time, which is the number of steps once it completes
+func (r Ramp) StepAt(now time.Time) int {
+	if r.Paused() {
+		now = r.PausedAt
+	}
+
+	elapsed := now.Sub(r.StartedAt)
+	for i, step := range r.Steps {
+		if elapsed < step.Duration {
+			return i
+		}
+		elapsed -= step.Duration
+	}
+
+	return len(r.Steps)
+}
+
+// basisPoints returns the rollout of the ramp at the time, and whether the ramp has completed
+func (r Ramp) basisPoints(now time.Time) (uint16, bool) {
+	step := r.StepAt(now)
+	if step == len(r.Steps) {
+		return r.Steps[len(r.Steps)-1].BasisPoints, true
+	}
+
+	return r.Steps[step].BasisPoints, false
+}
+
+// validate returns an error when the ramp has no steps or any of them can't be rolled out
+func (r Ramp) validate() error {
+	if len(r.Steps) == 0 {
+		return errors.New("ramp has no steps")
+	}
+
+	for _, step := range r.Steps {
+		if step.BasisPoints > MaxBasisPoints {
+			return fmt.Errorf("ramp percentage %g%% is over 100%%", float64(step.BasisPoints)/100)
+		}
+		if step.Duration < 0 {
+			return fmt.Errorf("ramp duration %s is negative", step.Duration)
+		}
+	}
+
+	return nil
+}
+
+// unixNano returns the time in nanoseconds since the epoch, 0 for the zero time
+func unixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+
+	return t.UnixNano()
+}
+
+// fromUnixNano returns the time of the nanoseconds since the epoch, the zero time for 0
+func fromUnixNano(n int64) time.Time {
+	if n == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(0, n)
+}
+
+// applyRamp rolls the feature out the percentage the ramp is at at the time, removing the ramp once it completes
+func (f *Feature) applyRamp(now time.Time) {
+	if f.ramp == nil {
+		return
+	}
+
+	basisPoints, done := f.ramp.basisPoints(now)
+	if done {
+		f.activateBasisPoints(basisPoints)
+		return
+	}
+
+	// set the rollout directly, as activating it replaces the ramp
+	f.percentage = uint8(basisPoints / 100)
+	f.fraction = uint8(basisPoints % 100)
+}
+
+func (f *Feature) pauseRamp(now time.Time) {
+	if f.ramp == nil || f.ramp.Paused() {
+		return
+	}
+
+	// the ramp may be shared with copies of the feature, so is replaced rather than modified
+	ramp := *f.ramp
+	ramp.PausedAt = now
+	f.ramp = &ramp
+}
+
+func (f *Feature) resumeRamp(now time.Time) {
+	if f.ramp == nil || !f.ramp.Paused() {
+		return
+	}
+
+	ramp := *f.ramp
+	ramp.StartedAt = ramp.StartedAt.Add(now.Sub(ramp.PausedAt))
+	ramp.PausedAt = time.Time{}
+	f.ramp = &ramp
+}
+
+// StartRamp progressively rolls the feature out through the steps, starting now. The ramp replaces
+// the rollout percentage of the feature until it completes, and is replaced by activating or deactivating it.
+func (m *Manager) StartRamp(feature *Feature, steps ...RampStep) error {
+	return m.StartRampContext(context.Background(), feature, steps...)
+}
+
+// StartRampContext progressively rolls the feature out through the steps, starting now, bounded by the context
+func (m *Manager) StartRampContext(ctx context.Context, feature *Feature, steps ...RampStep) error {
+	ramp := Ramp{Steps: append([]RampStep(nil), steps...), StartedAt: m.now()}
+	if err := ramp.validate(); err != nil {
+		return err
+	}
+
+	return m.update(ctx, feature, operation("start-ramp"), func(f *Feature) {
+		f.ramp = &ramp
+		f.applyRamp(ramp.StartedAt)
+	})
+}
+
+// PauseRamp holds the feature at the percentage of the current step of its ramp until it's resumed
+func (m *Manager) PauseRamp(feature *Feature) error {
+	return m.PauseRampContext(context.Background(), feature)
+}
+
+// PauseRampContext holds the feature at the percentage of the current step of its ramp, bounded by the context
+func (m *Manager) PauseRampContext(ctx context.Context, feature *Feature) error {
+	return m.update(ctx, feature, operation("pause-ramp"), func(f *Feature) {
+		f.pauseRamp(m.now())
+	})
+}
+
+// ResumeRamp resumes the paused ramp of the feature, where it was paused
+func (m *Manager) ResumeRamp(feature *Feature) error {
+	return m.ResumeRampContext(context.Background(), feature)
+}
+
+// ResumeRampContext resumes the paused ramp of the feature, bounded by the context
+func (m *Manager) ResumeRampContext(ctx context.Context, feature *Feature) error {
+	return m.update(ctx, feature, operation("resume-ramp"), func(f *Feature) {
+		f.resumeRamp(m.now())
+	})
+}
+
+// AbortRamp removes the ramp of the feature, rolling the feature back to 0% of teams
+func (m *Manager) AbortRamp(feature *Feature) error {
+	return m.AbortRampContext(context.Background(), feature)
+}
+
+// AbortRampContext removes the ramp of the feature, rolling it back to 0% of teams, bounded by the context
+func (m *Manager) AbortRampContext(ctx context.Context, feature *Feature) error {
+	return m.update(ctx, feature, operation("abort-ramp"), func(f *Feature) {
+		if f.ramp != nil {
+			f.activateBasisPoints(0)
+		}
+	})
+}
@@ -0,0 +1,150 @@
+package rollout
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vmihailenco/msgpack/v4"
+)
+
+func TestRampStepAt(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	ramp := Ramp{
+		Steps:     []RampStep{{100, day}, {500, day}, {2500, 2 * day}, {MaxBasisPoints, 0}},
+		StartedAt: start,
+	}
+
+	assert.Equal(t, 0, ramp.StepAt(start))
+	assert.Equal(t, 1, ramp.StepAt(start.Add(day)))
+	assert.Equal(t, 2, ramp.StepAt(start.Add(3*day)))
+	assert.Equal(t, 4, ramp.StepAt(start.Add(4*day)))
+
+	basisPoints, done := ramp.basisPoints(start.Add(36 * time.Hour))
+	assert.Equal(t, uint16(500), basisPoints)
+	assert.False(t, done)
+
+	basisPoints, done = ramp.basisPoints(start.Add(5 * day))
+	assert.Equal(t, uint16(MaxBasisPoints), basisPoints)
+	assert.True(t, done)
+
+	// paused ramps stay at the step they were paused at
+	ramp.PausedAt = start.Add(day)
+	assert.True(t, ramp.Paused())
+	assert.Equal(t, 1, ramp.StepAt(start.Add(10*day)))
+
+	data, err := msgpack.Marshal(ramp)
+	assert.NoError(t, err)
+
+	var out Ramp
+	assert.NoError(t, msgpack.Unmarshal(data, &out))
+	assert.Equal(t, ramp.Steps, out.Steps)
+	assert.True(t, ramp.StartedAt.Equal(out.StartedAt))
+	assert.True(t, ramp.PausedAt.Equal(out.PausedAt))
+
+	// the zero paused time survives encoding
+	ramp.PausedAt = time.Time{}
+	data, err = msgpack.Marshal(ramp)
+	assert.NoError(t, err)
+	assert.NoError(t, msgpack.Unmarshal(data, &out))
+	assert.False(t, out.Paused())
+}
+
+func TestRampValidate(t *testing.T) {
+	assert.EqualError(t, Ramp{}.validate(), "ramp has no steps")
+	assert.EqualError(t, Ramp{Steps: []RampStep{{10001, 0}}}.validate(), "ramp percentage 100.01% is over 100%")
+	assert.EqualError(t, Ramp{Steps: []RampStep{{100, -time.Hour}}}.validate(), "ramp duration -1h0m0s is negative")
+	assert.NoError(t, Ramp{Steps: []RampStep{{100, time.Hour}, {MaxBasisPoints, 0}}}.validate())
+}
+
+func TestFeatureRamp(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	f := NewFeature("example")
+	f.ramp = &Ramp{Steps: []RampStep{{100, time.Hour}, {5000, time.Hour}}, StartedAt: start}
+
+	assert.Equal(t, uint16(100), f.at(start).BasisPoints())
+	assert.Equal(t, uint16(5000), f.at(start.Add(90*time.Minute)).BasisPoints())
+
+	// the feature itself is left untouched
+	assert.Equal(t, uint16(0), f.BasisPoints())
+
+	state := f.at(start.Add(2 * time.Hour))
+	assert.Equal(t, uint16(5000), state.BasisPoints())
+	_, ramping := state.Ramp()
+	assert.False(t, ramping)
+
+	f.pauseRamp(start.Add(30 * time.Minute))
+	assert.Equal(t, uint16(100), f.at(start.Add(5*time.Hour)).BasisPoints())
+
+	// resuming continues where the ramp was paused
+	f.resumeRamp(start.Add(5 * time.Hour))
+	ramp, ramping := f.Ramp()
+	assert.True(t, ramping)
+	assert.False(t, ramp.Paused())
+	assert.Equal(t, uint16(100), f.at(start.Add(5*time.Hour+15*time.Minute)).BasisPoints())
+	assert.Equal(t, uint16(5000), f.at(start.Add(5*time.Hour+45*time.Minute)).BasisPoints())
+
+	data, err := msgpack.Marshal(f)
+	assert.NoError(t, err)
+
+	out := NewFeature("example")
+	assert.NoError(t, msgpack.Unmarshal(data, out))
+	decoded, ramping := out.Ramp()
+	assert.True(t, ramping)
+	assert.Equal(t, ramp.Steps, decoded.Steps)
+
+	// activating the feature replaces the ramp
+	f.activatePercentage(10)
+	_, ramping = f.Ramp()
+	assert.False(t, ramping)
+}
+
+func TestManagerRamp(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	manager := NewManagerWithStore(NewMemoryStore(), mockKeyPrefix, true, WithCache(time.Hour, time.Hour))
+	manager.now = func() time.Time { return now }
+
+	f := NewFeature("example")
+	assert.EqualError(t, manager.StartRamp(f), "ramp has no steps")
+	assert.NoError(t, manager.StartRamp(f, RampStep{0, time.Hour}, RampStep{MaxBasisPoints, 0}))
+
+	active, err := manager.IsActive(f)
+	assert.NoError(t, err)
+	assert.False(t, active)
+
+	// the percentage is computed at check time, even for cached features
+	now = now.Add(time.Hour)
+	active, err = manager.IsActive(f)
+	assert.NoError(t, err)
+	assert.True(t, active)
+
+	features, err := manager.List()
+	assert.NoError(t, err)
+	assert.Equal(t, uint16(MaxBasisPoints), features[0].BasisPoints())
+
+	assert.NoError(t, manager.StartRamp(f, RampStep{2500, time.Hour}, RampStep{MaxBasisPoints, 0}))
+	assert.NoError(t, manager.PauseRamp(f))
+
+	now = now.Add(2 * time.Hour)
+	active, err = manager.IsActive(f)
+	assert.NoError(t, err)
+	assert.False(t, active)
+
+	assert.NoError(t, manager.ResumeRamp(f))
+	now = now.Add(time.Hour)
+	active, err = manager.IsActive(f)
+	assert.NoError(t, err)
+	assert.True(t, active)
+
+	assert.NoError(t, manager.StartRamp(f, RampStep{2500, time.Hour}, RampStep{MaxBasisPoints, 0}))
+	assert.NoError(t, manager.AbortRamp(f))
+	assert.Equal(t, uint16(0), f.BasisPoints())
+
+	now = now.Add(time.Hour)
+	active, err = manager.IsActive(f)
+	assert.NoError(t, err)
+	assert.False(t, active)
+}
@@ -0,0 +1,347 @@
+package rollout
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+
+	msgpack "github.com/vmihailenco/msgpack/v4"
+	"github.com/vmihailenco/msgpack/v4/codes"
+)
+
+const (
+	// recordVersion is the version of the map records. Version 1 is the legacy tuple of fields appended one after
+	// the other, which every version reads, and version 2 a map of the fields by key, along with the version,
+	// which versions before the map records can't read.
+	recordVersion = 2
+
+	// recordVersionKey is the key of the version in the map records, written before the fields
+	recordVersionKey = "v"
+)
+
+// legacyRecordFields are the keys of the fields of legacy records, in the order they were appended in
+var legacyRecordFields = []string{
+	"percentage", "team_ids", "default", "actors", "actor_percentages", "segments", "blocked_team_ids", "fraction",
+	"salt", "hash", "rules", "variants", "payloads", "schedule", "ramp", "prerequisites", "metadata", "version",
+}
+
+// recordField is a field of the record a feature is stored as
+type recordField struct {
+	key   string
+	value interface{} // a pointer to the field
+	empty bool        // whether the field has its zero value, so is left out of the record
+}
+
+// recordFields returns the fields of the record the feature is stored as, with the persisted default
+// decoded into or encoded from the given pointer, as the declared default takes precedence over it
+func (f *Feature) recordFields(defaultActive *bool) []recordField {
+	return []recordField{
+		{"percentage", &f.percentage, f.percentage == 0},
+		{"fraction", &f.fraction, f.fraction == 0},
+		{"team_ids", &f.teamIDs, len(f.teamIDs) == 0},
+		{"default", defaultActive, !*defaultActive},
+		{"actors", &f.actors, len(f.actors) == 0},
+		{"actor_percentages", &f.actorPercentages, len(f.actorPercentages) == 0},
+		{"segments", &f.segments, len(f.segments) == 0},
+		{"blocked_team_ids", &f.blockedTeamIDs, len(f.blockedTeamIDs) == 0},
+		{"salt", &f.salt, f.salt == ""},
+		{"hash", &f.hash, f.hash == ""},
+		{"rules", &f.rules, len(f.rules) == 0},
+		{"variants", &f.variants, len(f.variants) == 0},
+		{"payloads", &f.payloads, len(f.payloads) == 0},
+		{"schedule", &f.schedule, len(f.schedule) == 0},
+		{"ramp", &f.ramp, f.ramp == nil},
+		{"prerequisites", &f.prerequisites, len(f.prerequisites) == 0},
+		{"metadata", &f.metadata, f.metadata.isZero()},
+		{"version", &f.version, f.version == 0},
+	}
+}
+
+// EncodeMsgpack implements msgpack.CustomEncoder, encoding the feature as a legacy record, which every version reads
+func (f *Feature) EncodeMsgpack(enc *msgpack.Encoder) error {
+	// fields are appended in the order of legacyRecordFields, so older readers ignore the ones they don't know about
+	return enc.EncodeMulti(f.percentage, f.teamIDs, f.defaultActive, f.actors, f.actorPercentages, f.segments, f.blockedTeamIDs, f.fraction,
+		f.salt, f.hash, f.rules, f.variants, f.payloads, f.schedule, f.ramp, f.prerequisites, f.metadata, f.version)
+}
+
+// versionedRecord encodes the feature as a map record, which versions before the map records can't read
+type versionedRecord struct {
+	*Feature
+}
+
+// EncodeMsgpack implements msgpack.CustomEncoder
+func (r versionedRecord) EncodeMsgpack(enc *msgpack.Encoder) error {
+	fields := r.recordFields(&r.defaultActive)
+
+	n := 1
+	for _, field := range fields {
+		if !field.empty {
+			n++
+		}
+	}
+
+	// fields are keyed, so readers ignore the ones they don't know about
+	if err := enc.EncodeMapLen(n); err != nil {
+		return err
+	}
+	if err := enc.EncodeMulti(recordVersionKey, recordVersion); err != nil {
+		return err
+	}
+
+	for _, field := range fields {
+		if field.empty {
+			continue
+		}
+
+		if err := enc.EncodeMulti(field.key, field.value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// encode returns the record the feature is stored as, replacing the current record. Map records are written
+// once the Manager is created with WithVersionedRecords, or the feature was migrated to them, and legacy ones
+// otherwise so readers which don't know about map records keep reading the feature.
+func (m *Manager) encode(feature *Feature, current []byte) ([]byte, error) {
+	v, err := version(current)
+	if err != nil {
+		return nil, err
+	}
+
+	if m.versionedRecords || v >= recordVersion {
+		return msgpack.Marshal(versionedRecord{feature})
+	}
+
+	return msgpack.Marshal(feature)
+}
+
+// DecodeMsgpack implements msgpack.CustomDecoder
+func (f *Feature) DecodeMsgpack(dec *msgpack.Decoder) error {
+	var defaultActive bool
+	f.percentage = 0
+	f.fraction = 0
+	f.teamIDs = nil
+	f.actors = nil
+	f.actorPercentages = nil
+	f.segments = nil
+	f.blockedTeamIDs = nil
+	f.salt = ""
+	f.hash = ""
+	f.rules = nil
+	f.variants = nil
+	f.payloads = nil
+	f.schedule = nil
+	f.ramp = nil
+	f.prerequisites = nil
+	f.metadata = Metadata{}
+	f.version = 0
+
+	fields := make(map[string]interface{})
+	for _, field := range f.recordFields(&defaultActive) {
+		fields[field.key] = field.value
+	}
+
+	code, err := dec.PeekCode()
+	if err != nil {
+		return err
+	}
+
+	if isMap(code) {
+		err = decodeRecord(dec, fields)
+	} else {
+		err = decodeLegacyRecord(dec, fields)
+	}
+	if err != nil {
+		return err
+	}
+
+	if !f.defaultDeclared {
+		// the declared default takes precedence over the persisted one
+		f.defaultActive = defaultActive
+	}
+
+	return nil
+}
+
+// decodeRecord decodes the fields of a map record, skipping the ones which aren't known
+func decodeRecord(dec *msgpack.Decoder, fields map[string]interface{}) error {
+	n, err := dec.DecodeMapLen()
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < n; i++ {
+		key, err := dec.DecodeString()
+		if err != nil {
+			return err
+		}
+
+		value, ok := fields[key]
+		if !ok {
+			// the version, and the fields added by newer versions
+			if err := dec.Skip(); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := dec.Decode(value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// decodeLegacyRecord decodes the fields of a legacy record, which end early when written by older versions
+func decodeLegacyRecord(dec *msgpack.Decoder, fields map[string]interface{}) error {
+	for i, key := range legacyRecordFields {
+		if i > 0 {
+			if _, err := dec.PeekCode(); err == io.EOF {
+				break
+			}
+		}
+
+		if err := dec.Decode(fields[key]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// encodeTuple encodes the values as a msgpack array, so readers which don't know about them can skip them
+func encodeTuple(enc *msgpack.Encoder, values ...interface{}) error {
+	if err := enc.EncodeArrayLen(len(values)); err != nil {
+		return err
+	}
+
+	return enc.EncodeMulti(values...)
+}
+
+// decodeTuple decodes the values of an array encoded by encodeTuple, skipping the values appended by newer versions
+func decodeTuple(dec *msgpack.Decoder, values ...interface{}) error {
+	n, err := dec.DecodeArrayLen()
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < n; i++ {
+		if i >= len(values) {
+			if err := dec.Skip(); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := dec.Decode(values[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// isMap returns whether the code starts a msgpack map
+func isMap(code codes.Code) bool {
+	return codes.IsFixedMap(code) || code == codes.Map16 || code == codes.Map32
+}
+
+// version returns the version of the format of the record
+func version(data []byte) (int, error) {
+	if len(data) == 0 || !isMap(codes.Code(data[0])) {
+		return 1, nil
+	}
+
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	n, err := dec.DecodeMapLen()
+	if err != nil {
+		return 0, err
+	}
+
+	for i := 0; i < n; i++ {
+		key, err := dec.DecodeString()
+		if err != nil {
+			return 0, err
+		}
+
+		if key == recordVersionKey {
+			return dec.DecodeInt()
+		}
+
+		if err := dec.Skip(); err != nil {
+			return 0, err
+		}
+	}
+
+	return 0, nil
+}
+
+// Migrate rewrites every feature persisted under the key prefix which is stored as a legacy record as a map record,
+// returning the number of features rewritten. Features are only rewritten when they're unchanged since read, and
+// are written as map records from then on, which versions before the map records can't read.
+func (m *Manager) Migrate() (int, error) {
+	return m.MigrateContext(context.Background())
+}
+
+// MigrateContext rewrites every feature stored as a legacy record as a map record, bounded by the context
+func (m *Manager) MigrateContext(ctx context.Context) (int, error) {
+	prefix := m.keyPrefix + ":"
+
+	keys, err := m.store.List(ctx, prefix)
+	if err != nil {
+		return 0, err
+	}
+
+	var migrated int
+	for _, key := range keys {
+		rewritten, err := m.migrate(ctx, NewFeature(strings.TrimPrefix(key, prefix)))
+		if err != nil {
+			return migrated, err
+		}
+		if rewritten {
+			migrated++
+		}
+	}
+
+	return migrated, nil
+}
+
+// migrate rewrites the feature when it's stored in an older format, reporting whether it was rewritten
+func (m *Manager) migrate(ctx context.Context, feature *Feature) (bool, error) {
+	for attempt := 0; attempt < maxUpdateAttempts; attempt++ {
+		current, err := m.store.Get(ctx, m.keyName(feature))
+		if err != nil || current == nil {
+			return false, err
+		}
+
+		v, err := version(current)
+		if err != nil {
+			return false, err
+		}
+		if v >= recordVersion {
+			return false, nil
+		}
+
+		state, err := m.decode(feature, current)
+		if err != nil {
+			return false, err
+		}
+
+		data, err := msgpack.Marshal(versionedRecord{state})
+		if err != nil {
+			return false, err
+		}
+
+		// the feature is unchanged, so the change isn't announced
+		swapped, err := m.store.CompareAndSet(ctx, m.keyName(feature), current, data)
+		if err != nil || swapped {
+			return swapped, err
+		}
+	}
+
+	return false, &ConflictError{Feature: feature.Name(), Attempts: maxUpdateAttempts}
+}
@@ -0,0 +1,195 @@
+package rollout
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vmihailenco/msgpack/v4"
+)
+
+// legacyRecord encodes the fields the way versions before the map records did
+func legacyRecord(t *testing.T, fields ...interface{}) []byte {
+	var buf bytes.Buffer
+	assert.NoError(t, msgpack.NewEncoder(&buf).EncodeMulti(fields...))
+
+	return buf.Bytes()
+}
+
+func TestDecodeLegacyRecord(t *testing.T) {
+	data := legacyRecord(t, uint8(10), []int64{1}, true, map[ActorKind][]string{KindUser: {"abc"}}, map[ActorKind]uint8{KindAccount: 5},
+		[]string{"beta"}, []int64{2}, uint8(5), "checkout", HashMurmur3)
+
+	f := NewFeature("example")
+	assert.NoError(t, f.load(data))
+	assert.EqualValues(t, 1005, f.BasisPoints())
+	assert.Equal(t, []int64{1}, f.TeamIDs())
+	assert.True(t, f.Default())
+	assert.Equal(t, []Actor{NewActor(KindUser, "abc")}, f.Actors())
+	assert.EqualValues(t, 5, f.ActorPercentage(KindAccount))
+	assert.Equal(t, []string{"beta"}, f.Segments())
+	assert.Equal(t, []int64{2}, f.BlockedTeamIDs())
+	assert.Equal(t, "checkout", f.Salt())
+	assert.Equal(t, HashMurmur3, f.Hash())
+	assert.Empty(t, f.Variants())
+}
+
+func TestRecord(t *testing.T) {
+	f := NewFeature("example")
+	f.activatePercentage(25)
+	f.activateTeam(1)
+	f.setVariant("blue", 1)
+	f.addPrerequisite("other")
+
+	data, err := msgpack.Marshal(versionedRecord{f})
+	assert.NoError(t, err)
+
+	v, err := version(data)
+	assert.NoError(t, err)
+	assert.Equal(t, recordVersion, v)
+
+	// empty fields are left out
+	var record map[string]interface{}
+	assert.NoError(t, msgpack.Unmarshal(data, &record))
+	assert.Len(t, record, 5)
+	assert.Contains(t, record, "variants")
+	assert.NotContains(t, record, "salt")
+
+	out := NewFeature("example")
+	assert.NoError(t, out.load(data))
+	assert.EqualValues(t, 25, out.Percentage())
+	assert.Equal(t, []int64{1}, out.TeamIDs())
+	assert.Equal(t, f.Variants(), out.Variants())
+	assert.Equal(t, []string{"other"}, out.Prerequisites())
+
+	// fields added by newer versions are ignored
+	record["v"] = recordVersion + 1
+	record["flavour"] = map[string]string{"name": "lime"}
+	data, err = msgpack.Marshal(record)
+	assert.NoError(t, err)
+
+	out = NewFeature("example")
+	assert.NoError(t, out.load(data))
+	assert.EqualValues(t, 25, out.Percentage())
+	assert.Equal(t, []string{"other"}, out.Prerequisites())
+
+	v, err = version(legacyRecord(t, uint8(10), []int64{1}))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, v)
+}
+
+func TestLegacyRecord(t *testing.T) {
+	f := NewFeature("example")
+	f.activatePercentage(25)
+	f.activateTeam(1)
+	f.setVariant("blue", 1)
+
+	data, err := msgpack.Marshal(f)
+	assert.NoError(t, err)
+
+	v, err := version(data)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, v)
+
+	// readers before the appended fields read the percentage and teams
+	var percentage uint8
+	var teamIDs []int64
+	assert.NoError(t, msgpack.NewDecoder(bytes.NewReader(data)).DecodeMulti(&percentage, &teamIDs))
+	assert.EqualValues(t, 25, percentage)
+	assert.Equal(t, []int64{1}, teamIDs)
+
+	out := NewFeature("example")
+	assert.NoError(t, out.load(data))
+	assert.EqualValues(t, 25, out.Percentage())
+	assert.Equal(t, f.Variants(), out.Variants())
+}
+
+func TestManagerRecordFormat(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	manager := NewManagerWithStore(store, mockKeyPrefix, true)
+
+	recordVersionOf := func(feature *Feature) int {
+		data, err := store.Get(ctx, manager.keyName(feature))
+		assert.NoError(t, err)
+		v, err := version(data)
+		assert.NoError(t, err)
+
+		return v
+	}
+
+	// legacy records are written unless opted in to map records
+	legacy := NewFeature("legacy")
+	assert.NoError(t, manager.ActivateTeam(1, legacy))
+	assert.Equal(t, 1, recordVersionOf(legacy))
+
+	versioned := NewFeature("versioned")
+	assert.NoError(t, NewManagerWithStore(store, mockKeyPrefix, true, WithVersionedRecords()).ActivateTeam(1, versioned))
+	assert.Equal(t, recordVersion, recordVersionOf(versioned))
+
+	// map records are kept once written, whether opted in or migrated
+	assert.NoError(t, manager.ActivateTeam(2, versioned))
+	assert.Equal(t, recordVersion, recordVersionOf(versioned))
+
+	_, err := manager.Migrate()
+	assert.NoError(t, err)
+	assert.NoError(t, manager.ActivateTeam(2, legacy))
+	assert.Equal(t, recordVersion, recordVersionOf(legacy))
+
+	active, err := manager.IsTeamActive(1, legacy)
+	assert.NoError(t, err)
+	assert.True(t, active)
+}
+
+func TestManagerMigrate(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	manager := NewManagerWithStore(store, mockKeyPrefix, true)
+
+	legacy := NewFeature("legacy")
+	_, err := store.CompareAndSet(ctx, manager.keyName(legacy), nil, legacyRecord(t, uint8(0), []int64{1, 2}, true))
+	assert.NoError(t, err)
+
+	current := NewFeature("current")
+	assert.NoError(t, NewManagerWithStore(store, mockKeyPrefix, true, WithVersionedRecords()).ActivateTeam(3, current))
+	before, err := store.Get(ctx, manager.keyName(current))
+	assert.NoError(t, err)
+
+	migrated, err := manager.Migrate()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, migrated)
+
+	data, err := store.Get(ctx, manager.keyName(legacy))
+	assert.NoError(t, err)
+	v, err := version(data)
+	assert.NoError(t, err)
+	assert.Equal(t, recordVersion, v)
+
+	active, err := manager.IsTeamActive(2, legacy)
+	assert.NoError(t, err)
+	assert.True(t, active)
+
+	features, err := manager.List()
+	assert.NoError(t, err)
+	assert.True(t, features[1].Default())
+
+	// records in the current format are left untouched
+	after, err := store.Get(ctx, manager.keyName(current))
+	assert.NoError(t, err)
+	assert.Equal(t, before, after)
+
+	migrated, err = manager.Migrate()
+	assert.NoError(t, err)
+	assert.Zero(t, migrated)
+}
+
+func TestDecodeTuple(t *testing.T) {
+	// values appended by newer versions are skipped
+	data, err := msgpack.Marshal([]interface{}{"blue", 3, map[string]string{"hex": "#00f"}})
+	assert.NoError(t, err)
+
+	var v Variant
+	assert.NoError(t, msgpack.Unmarshal(data, &v))
+	assert.Equal(t, Variant{Name: "blue", Weight: 3}, v)
+}
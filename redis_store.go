@@ -0,0 +1,273 @@
+package rollout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	redis "github.com/go-redis/redis/v7"
+)
+
+const (
+	// ScanCount is the number of keys redis stores request per SCAN iteration when listing keys
+	ScanCount = 100
+
+	// JournalField is the field of the stream entries holding the entries of the logs kept by redis stores
+	JournalField = "entry"
+)
+
+// CompareAndSetScript is the lua script redis stores use to implement Store.CompareAndSet.
+// It sets KEYS[1] to ARGV[2] only when it still holds ARGV[1], an empty ARGV[1] meaning the key must not exist,
+// returning 1 when the key was set and 0 otherwise.
+const CompareAndSetScript = `
+local current = redis.call("GET", KEYS[1])
+if (current or "") ~= ARGV[1] then
+	return 0
+end
+redis.call("SET", KEYS[1], ARGV[2])
+return 1
+`
+
+var compareAndSet = redis.NewScript(CompareAndSetScript)
+
+// subscriber is implemented by the go-redis v7 clients supporting pub/sub
+type subscriber interface {
+	Subscribe(channels ...string) *redis.PubSub
+}
+
+// RedisStore is a Store, Notifier and Journal backed by a go-redis v7 client
+type RedisStore struct {
+	client redis.Cmdable
+}
+
+// NewRedisStore constructs a new RedisStore instance
+func NewRedisStore(client redis.Cmdable) *RedisStore {
+	return &RedisStore{client: client}
+}
+
+// withContext returns the client bound to the context, so its deadline is respected by the clients supporting it
+func (s *RedisStore) withContext(ctx context.Context) (redis.Cmdable, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	switch client := s.client.(type) {
+	case *redis.Client:
+		return client.WithContext(ctx), nil
+	case *redis.ClusterClient:
+		return client.WithContext(ctx), nil
+	case *redis.Ring:
+		return client.WithContext(ctx), nil
+	default:
+		return client, nil
+	}
+}
+
+// Get implements Store
+func (s *RedisStore) Get(ctx context.Context, key string) ([]byte, error) {
+	client, err := s.withContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := client.Get(key).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// MGet implements Store
+func (s *RedisStore) MGet(ctx context.Context, keys ...string) ([][]byte, error) {
+	client, err := s.withContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	val, err := client.MGet(keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([][]byte, len(val))
+
+	for i, v := range val {
+		switch t := v.(type) {
+		case nil:
+			// key wasn't found in redis
+
+		case string:
+			results[i] = []byte(t)
+
+		default:
+			return nil, fmt.Errorf("unexpected type (%T) for msgpack value: %v", v, v)
+		}
+	}
+
+	return results, nil
+}
+
+// CompareAndSet implements Store
+func (s *RedisStore) CompareAndSet(ctx context.Context, key string, old, value []byte) (bool, error) {
+	client, err := s.withContext(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	return compareAndSet.Run(client, []string{key}, string(old), value).Bool()
+}
+
+// Delete implements Store
+func (s *RedisStore) Delete(ctx context.Context, key string) (bool, error) {
+	client, err := s.withContext(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	count, err := client.Del(key).Result()
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+// List implements Store
+func (s *RedisStore) List(ctx context.Context, prefix string) ([]string, error) {
+	client, err := s.withContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var cursor uint64
+	var allKeys []string
+
+	for {
+		var keys []string
+		keys, cursor, err = client.Scan(cursor, prefix+"*", ScanCount).Result()
+		if err != nil {
+			return nil, err
+		}
+
+		allKeys = append(allKeys, keys...)
+
+		if cursor == 0 {
+			break
+		}
+	}
+
+	return allKeys, nil
+}
+
+// Append implements Journal, adding the entry to the stream of the key
+func (s *RedisStore) Append(ctx context.Context, key string, entry []byte, max int) error {
+	if max < 0 {
+		return ErrNegativeJournalMax
+	}
+
+	client, err := s.withContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	return client.XAdd(&redis.XAddArgs{
+		Stream: key,
+		MaxLen: int64(max),
+		Values: map[string]interface{}{JournalField: entry},
+	}).Err()
+}
+
+// Entries implements Journal, reading the stream of the key
+func (s *RedisStore) Entries(ctx context.Context, key string, count int) ([][]byte, error) {
+	client, err := s.withContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []redis.XMessage
+	if count > 0 {
+		messages, err = client.XRevRangeN(key, "+", "-", int64(count)).Result()
+		reverse(messages)
+	} else {
+		messages, err = client.XRange(key, "-", "+").Result()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([][]byte, len(messages))
+	for i, msg := range messages {
+		entry, ok := msg.Values[JournalField].(string)
+		if !ok {
+			return nil, fmt.Errorf("unexpected type (%T) for journal entry: %v", msg.Values[JournalField], msg.Values[JournalField])
+		}
+		entries[i] = []byte(entry)
+	}
+
+	return entries, nil
+}
+
+// reverse reverses the order of the stream messages, read from the newest
+func reverse(messages []redis.XMessage) {
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+}
+
+// Publish implements Notifier
+func (s *RedisStore) Publish(ctx context.Context, channel string, message string) error {
+	client, err := s.withContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	return client.Publish(channel, message).Err()
+}
+
+// Subscribe implements Notifier, requiring the client to support pub/sub
+func (s *RedisStore) Subscribe(ctx context.Context, channel string) (<-chan string, error) {
+	client, ok := s.client.(subscriber)
+	if !ok {
+		return nil, errors.New("redis client doesn't support subscribing")
+	}
+
+	pubsub := client.Subscribe(channel)
+
+	// wait for the subscription to be confirmed
+	if _, err := pubsub.Receive(); err != nil {
+		_ = pubsub.Close()
+		return nil, err
+	}
+
+	messages := make(chan string)
+
+	go func() {
+		defer close(messages)
+		defer pubsub.Close()
+
+		ch := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+
+			case msg, ok := <-ch:
+				if !ok {
+					return
+				}
+
+				select {
+				case messages <- msg.Payload:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return messages, nil
+}
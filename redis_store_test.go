@@ -0,0 +1,68 @@
+package rollout
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedisStoreMGet(t *testing.T) {
+	client := &MockClient{}
+	store := NewRedisStore(client)
+
+	client.features = []*Feature{NewFeature("example1")}
+	values, err := store.MGet(context.Background(), mockKeyPrefix+":example1", mockKeyPrefix+":example2")
+	assert.NoError(t, err)
+	assert.Len(t, values, 2)
+	assert.NotNil(t, values[0])
+	assert.Nil(t, values[1])
+	assert.True(t, client.mgetWasCalled)
+
+	// mock error
+	client.shouldError = true
+	_, err = store.MGet(context.Background(), mockKeyPrefix+":example1")
+	assert.EqualError(t, err, "mock error")
+}
+
+func TestRedisStoreList(t *testing.T) {
+	client := &MockClient{}
+	store := NewRedisStore(client)
+
+	// no keys in redis
+	keys, err := store.List(context.Background(), mockKeyPrefix+":")
+	assert.NoError(t, err)
+	assert.Empty(t, keys)
+
+	// keys spread over multiple iterations
+	client.features = []*Feature{
+		NewFeature("example1"),
+		NewFeature("example2"),
+		NewFeature("example3"),
+	}
+	keys, err = store.List(context.Background(), mockKeyPrefix+":")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{mockKeyPrefix + ":example1", mockKeyPrefix + ":example2", mockKeyPrefix + ":example3"}, keys)
+
+	// mock error
+	client.shouldError = true
+	_, err = store.List(context.Background(), mockKeyPrefix+":")
+	assert.EqualError(t, err, "mock error")
+}
+
+func TestRedisStoreContext(t *testing.T) {
+	client := &MockClient{}
+	store := NewRedisStore(client)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// redis isn't called once the context is done
+	_, err := store.Get(ctx, mockKeyPrefix+":example")
+	assert.Equal(t, context.Canceled, err)
+	assert.False(t, client.getWasCalled)
+
+	_, err = store.CompareAndSet(ctx, mockKeyPrefix+":example", nil, []byte("a"))
+	assert.Equal(t, context.Canceled, err)
+	assert.False(t, client.setWasCalled)
+}
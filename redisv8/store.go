@@ -0,0 +1,222 @@
+// Package redisv8 backs a rollout.Manager with a go-redis v8 client
+package redisv8
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/go-redis/redis/v8"
+	rollout "github.com/salesloft/gorollout"
+)
+
+var compareAndSet = redis.NewScript(rollout.CompareAndSetScript)
+
+// NewManager constructs a new rollout.Manager instance backed by the go-redis v8 client
+func NewManager(client redis.UniversalClient, keyPrefix string, randomizePercentage bool, opts ...rollout.Option) *rollout.Manager {
+	return rollout.NewManagerWithStore(NewStore(client), keyPrefix, randomizePercentage, opts...)
+}
+
+// Store is a rollout.Store, rollout.Notifier and rollout.Journal backed by a go-redis v8 client
+type Store struct {
+	client redis.UniversalClient
+}
+
+// NewStore constructs a new Store instance
+func NewStore(client redis.UniversalClient) *Store {
+	return &Store{client: client}
+}
+
+// Get implements rollout.Store
+func (s *Store) Get(ctx context.Context, key string) ([]byte, error) {
+	data, err := s.client.Get(ctx, key).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// MGet implements rollout.Store
+func (s *Store) MGet(ctx context.Context, keys ...string) ([][]byte, error) {
+	val, err := s.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([][]byte, len(val))
+
+	for i, v := range val {
+		switch t := v.(type) {
+		case nil:
+			// key wasn't found in redis
+
+		case string:
+			results[i] = []byte(t)
+
+		default:
+			return nil, fmt.Errorf("unexpected type (%T) for msgpack value: %v", v, v)
+		}
+	}
+
+	return results, nil
+}
+
+// CompareAndSet implements rollout.Store
+func (s *Store) CompareAndSet(ctx context.Context, key string, old, value []byte) (bool, error) {
+	return compareAndSet.Run(ctx, s.client, []string{key}, string(old), value).Bool()
+}
+
+// Delete implements rollout.Store
+func (s *Store) Delete(ctx context.Context, key string) (bool, error) {
+	count, err := s.client.Del(ctx, key).Result()
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+// List implements rollout.Store, scanning every master node of a cluster
+func (s *Store) List(ctx context.Context, prefix string) ([]string, error) {
+	cluster, ok := s.client.(*redis.ClusterClient)
+	if !ok {
+		return scan(ctx, s.client, prefix)
+	}
+
+	var allKeys []string
+	var mu sync.Mutex
+
+	err := cluster.ForEachMaster(ctx, func(ctx context.Context, client *redis.Client) error {
+		keys, err := scan(ctx, client, prefix)
+		if err != nil {
+			return err
+		}
+
+		mu.Lock()
+		allKeys = append(allKeys, keys...)
+		mu.Unlock()
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return allKeys, nil
+}
+
+func scan(ctx context.Context, client redis.Cmdable, prefix string) ([]string, error) {
+	var cursor uint64
+	var allKeys []string
+
+	for {
+		var keys []string
+		var err error
+		keys, cursor, err = client.Scan(ctx, cursor, prefix+"*", rollout.ScanCount).Result()
+		if err != nil {
+			return nil, err
+		}
+
+		allKeys = append(allKeys, keys...)
+
+		if cursor == 0 {
+			break
+		}
+	}
+
+	return allKeys, nil
+}
+
+// Append implements rollout.Journal, adding the entry to the stream of the key
+func (s *Store) Append(ctx context.Context, key string, entry []byte, max int) error {
+	if max < 0 {
+		return rollout.ErrNegativeJournalMax
+	}
+
+	return s.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: key,
+		MaxLen: int64(max),
+		Values: map[string]interface{}{rollout.JournalField: entry},
+	}).Err()
+}
+
+// Entries implements rollout.Journal, reading the stream of the key
+func (s *Store) Entries(ctx context.Context, key string, count int) ([][]byte, error) {
+	var messages []redis.XMessage
+	var err error
+	if count > 0 {
+		messages, err = s.client.XRevRangeN(ctx, key, "+", "-", int64(count)).Result()
+		reverse(messages)
+	} else {
+		messages, err = s.client.XRange(ctx, key, "-", "+").Result()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([][]byte, len(messages))
+	for i, msg := range messages {
+		entry, ok := msg.Values[rollout.JournalField].(string)
+		if !ok {
+			return nil, fmt.Errorf("unexpected type (%T) for journal entry: %v", msg.Values[rollout.JournalField], msg.Values[rollout.JournalField])
+		}
+		entries[i] = []byte(entry)
+	}
+
+	return entries, nil
+}
+
+// reverse reverses the order of the stream messages, read from the newest
+func reverse(messages []redis.XMessage) {
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+}
+
+// Publish implements rollout.Notifier
+func (s *Store) Publish(ctx context.Context, channel string, message string) error {
+	return s.client.Publish(ctx, channel, message).Err()
+}
+
+// Subscribe implements rollout.Notifier
+func (s *Store) Subscribe(ctx context.Context, channel string) (<-chan string, error) {
+	pubsub := s.client.Subscribe(ctx, channel)
+
+	// wait for the subscription to be confirmed
+	if _, err := pubsub.Receive(ctx); err != nil {
+		_ = pubsub.Close()
+		return nil, err
+	}
+
+	messages := make(chan string)
+
+	go func() {
+		defer close(messages)
+		defer pubsub.Close()
+
+		ch := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+
+			case msg, ok := <-ch:
+				if !ok {
+					return
+				}
+
+				select {
+				case messages <- msg.Payload:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return messages, nil
+}
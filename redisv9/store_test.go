@@ -0,0 +1,115 @@
+package redisv9
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	rollout "github.com/salesloft/gorollout"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	store := NewStore(redis.NewClient(&redis.Options{Addr: server.Addr()}))
+
+	// key doesn't exist
+	value, err := store.Get(ctx, "rollout:example1")
+	assert.NoError(t, err)
+	assert.Nil(t, value)
+
+	// set only when the key doesn't exist, then only when it holds the old value
+	swapped, err := store.CompareAndSet(ctx, "rollout:example1", nil, []byte("a"))
+	assert.NoError(t, err)
+	assert.True(t, swapped)
+
+	swapped, err = store.CompareAndSet(ctx, "rollout:example1", nil, []byte("b"))
+	assert.NoError(t, err)
+	assert.False(t, swapped)
+
+	swapped, err = store.CompareAndSet(ctx, "rollout:example1", []byte("a"), []byte("c"))
+	assert.NoError(t, err)
+	assert.True(t, swapped)
+
+	value, err = store.Get(ctx, "rollout:example1")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("c"), value)
+
+	// multiple keys
+	_, err = store.CompareAndSet(ctx, "rollout:example2", nil, []byte("d"))
+	assert.NoError(t, err)
+	_, err = store.CompareAndSet(ctx, "other:example3", nil, []byte("e"))
+	assert.NoError(t, err)
+
+	values, err := store.MGet(ctx, "rollout:example1", "rollout:missing", "rollout:example2")
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("c"), nil, []byte("d")}, values)
+
+	keys, err := store.List(ctx, "rollout:")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"rollout:example1", "rollout:example2"}, keys)
+
+	// delete
+	deleted, err := store.Delete(ctx, "rollout:example1")
+	assert.NoError(t, err)
+	assert.True(t, deleted)
+
+	deleted, err = store.Delete(ctx, "rollout:example1")
+	assert.NoError(t, err)
+	assert.False(t, deleted)
+
+	// logs, trimmed to their most recent entries
+	for _, entry := range []string{"a", "b", "c"} {
+		assert.NoError(t, store.Append(ctx, "rollout-history:example1", []byte(entry), 2))
+	}
+
+	entries, err := store.Entries(ctx, "rollout-history:example1", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("b"), []byte("c")}, entries)
+
+	entries, err = store.Entries(ctx, "rollout-history:example1", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("c")}, entries)
+
+	entries, err = store.Entries(ctx, "rollout-history:missing", 0)
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+
+	// logs without a max keep every entry
+	for _, entry := range []string{"a", "b", "c"} {
+		assert.NoError(t, store.Append(ctx, "rollout-history:example2", []byte(entry), 0))
+	}
+
+	entries, err = store.Entries(ctx, "rollout-history:example2", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("a"), []byte("b"), []byte("c")}, entries)
+
+	assert.Equal(t, rollout.ErrNegativeJournalMax, store.Append(ctx, "rollout-history:example2", []byte("d"), -1))
+}
+
+func TestManager(t *testing.T) {
+	server := miniredis.RunT(t)
+	manager := NewManager(redis.NewClient(&redis.Options{Addr: server.Addr()}), "rollout", false, rollout.WithCache(time.Hour, time.Hour))
+	other := NewManager(redis.NewClient(&redis.Options{Addr: server.Addr()}), "rollout", false)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	assert.NoError(t, manager.Subscribe(ctx))
+
+	f := rollout.NewFeature("example")
+
+	active, err := manager.IsTeamActive(1, f)
+	assert.NoError(t, err)
+	assert.False(t, active)
+
+	// changes made elsewhere are announced
+	assert.NoError(t, other.ActivateTeam(1, rollout.NewFeature("example")))
+
+	assert.Eventually(t, func() bool {
+		active, err := manager.IsTeamActive(1, f)
+		return err == nil && active
+	}, time.Second, time.Millisecond)
+}
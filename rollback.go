@@ -0,0 +1,58 @@
+package rollout
+
+import (
+	"context"
+	"fmt"
+)
+
+// restore sets the feature to the state, a copy of the feature as it was recorded in its history
+func (f *Feature) restore(state *Feature) {
+	f.defaultActive = state.defaultActive
+	f.percentage = state.percentage
+	f.fraction = state.fraction
+	f.teamIDs = state.teamIDs
+	f.actors = state.actors
+	f.actorPercentages = state.actorPercentages
+	f.segments = state.segments
+	f.blockedTeamIDs = state.blockedTeamIDs
+	f.salt = state.salt
+	f.hash = state.hash
+	f.rules = state.rules
+	f.variants = state.variants
+	f.payloads = state.payloads
+	f.schedule = state.schedule
+	f.ramp = state.ramp
+	f.prerequisites = state.prerequisites
+	f.metadata = state.metadata
+}
+
+// Rollback atomically restores the feature to the state the change making the version left it in, which must still
+// be in its history. The rollback is itself a change, making a new version rather than removing the later ones.
+// Scheduled changes of the restored state which have since become due are made, and its ramp is at the step it would be at now.
+func (m *Manager) Rollback(feature *Feature, toVersion int64) error {
+	return m.RollbackContext(context.Background(), feature, toVersion)
+}
+
+// RollbackContext restores the feature to the state the change making the version left it in, bounded by the context
+func (m *Manager) RollbackContext(ctx context.Context, feature *Feature, toVersion int64) error {
+	history, err := m.HistoryContext(ctx, feature)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range history {
+		if entry.Version != toVersion {
+			continue
+		}
+
+		if entry.After == nil {
+			return fmt.Errorf("feature %q was deleted by version %d", feature.Name(), toVersion)
+		}
+
+		return m.update(ctx, feature, operation("rollback", toVersion), func(f *Feature) {
+			f.restore(entry.After)
+		})
+	}
+
+	return fmt.Errorf("version %d of feature %q is not in its history", toVersion, feature.Name())
+}
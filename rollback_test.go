@@ -0,0 +1,64 @@
+package rollout
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManagerRollback(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	manager := NewManagerWithStore(NewMemoryStore(), mockKeyPrefix, true, WithCache(time.Hour, time.Hour), WithHistory(DefaultHistoryLimit))
+	manager.now = func() time.Time { return now }
+
+	f := NewFeature("example")
+	assert.NoError(t, manager.ActivateTeam(1, f))
+	assert.NoError(t, manager.ActivatePercentage(f, 5))
+	assert.NoError(t, manager.StartRamp(f, RampStep{2500, time.Hour}, RampStep{MaxBasisPoints, 0}))
+	assert.NoError(t, manager.ActivateTeam(2, f))
+
+	// a bad ramp is undone along with the changes made since
+	now = now.Add(30 * time.Minute)
+	ctx := ContextWithReason(ContextWithAuthor(context.Background(), "alice"), "ramp broke checkout")
+	assert.NoError(t, manager.RollbackContext(ctx, f, 2))
+	assert.EqualValues(t, 5, f.Version())
+
+	state, err := manager.load(context.Background(), f)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 5, state.Percentage())
+	assert.Equal(t, []int64{1}, state.TeamIDs())
+	_, ok := state.Ramp()
+	assert.False(t, ok)
+	assert.Equal(t, "alice", state.Metadata().UpdatedBy)
+
+	history, err := manager.History(f)
+	assert.NoError(t, err)
+	assert.Len(t, history, 5)
+	entry := history[4]
+	assert.EqualValues(t, 5, entry.Version)
+	assert.Equal(t, "rollback 2", entry.Operation)
+	assert.Equal(t, "ramp broke checkout", entry.Reason)
+	assert.Equal(t, []int64{1, 2}, entry.Before.TeamIDs())
+
+	// rolling back to a later version restores the ramp where it would be now
+	assert.NoError(t, manager.Rollback(f, 4))
+	state, err = manager.load(context.Background(), f)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 25, state.Percentage())
+	assert.Equal(t, []int64{1, 2}, state.TeamIDs())
+
+	// deleted features are restored
+	_, err = manager.Delete(f)
+	assert.NoError(t, err)
+	assert.EqualError(t, manager.Rollback(f, 7), `feature "example" was deleted by version 7`)
+	assert.NoError(t, manager.Rollback(f, 1))
+	assert.EqualValues(t, 8, f.Version())
+
+	active, err := manager.IsTeamActive(1, f)
+	assert.NoError(t, err)
+	assert.True(t, active)
+
+	assert.EqualError(t, manager.Rollback(f, 42), `version 42 of feature "example" is not in its history`)
+}
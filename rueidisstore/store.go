@@ -0,0 +1,241 @@
+// Package rueidisstore backs a rollout.Manager with a rueidis client
+package rueidisstore
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/redis/rueidis"
+	rollout "github.com/salesloft/gorollout"
+)
+
+const (
+	// resubscribeDelay is how long to wait before resubscribing after the connection is lost
+	resubscribeDelay = time.Second
+)
+
+var compareAndSet = rueidis.NewLuaScript(rollout.CompareAndSetScript)
+
+// NewManager constructs a new rollout.Manager instance backed by the rueidis client
+func NewManager(client rueidis.Client, keyPrefix string, randomizePercentage bool, opts ...rollout.Option) *rollout.Manager {
+	return rollout.NewManagerWithStore(NewStore(client), keyPrefix, randomizePercentage, opts...)
+}
+
+// Store is a rollout.Store, rollout.Notifier and rollout.Journal backed by a rueidis client
+type Store struct {
+	client rueidis.Client
+}
+
+// NewStore constructs a new Store instance
+func NewStore(client rueidis.Client) *Store {
+	return &Store{client: client}
+}
+
+// Get implements rollout.Store
+func (s *Store) Get(ctx context.Context, key string) ([]byte, error) {
+	data, err := s.client.Do(ctx, s.client.B().Get().Key(key).Build()).AsBytes()
+	if err != nil {
+		if rueidis.IsRedisNil(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// MGet implements rollout.Store, splitting the keys by slot for clusters
+func (s *Store) MGet(ctx context.Context, keys ...string) ([][]byte, error) {
+	val, err := rueidis.MGet(s.client, ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([][]byte, len(keys))
+
+	for i, key := range keys {
+		msg, ok := val[key]
+		if !ok || msg.IsNil() {
+			// key wasn't found in redis
+			continue
+		}
+
+		if results[i], err = msg.AsBytes(); err != nil {
+			return nil, err
+		}
+	}
+
+	return results, nil
+}
+
+// CompareAndSet implements rollout.Store
+func (s *Store) CompareAndSet(ctx context.Context, key string, old, value []byte) (bool, error) {
+	swapped, err := compareAndSet.Exec(ctx, s.client, []string{key}, []string{string(old), string(value)}).AsInt64()
+	if err != nil {
+		return false, err
+	}
+
+	return swapped == 1, nil
+}
+
+// Delete implements rollout.Store
+func (s *Store) Delete(ctx context.Context, key string) (bool, error) {
+	count, err := s.client.Do(ctx, s.client.B().Del().Key(key).Build()).AsInt64()
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+// List implements rollout.Store, scanning every node of a cluster
+func (s *Store) List(ctx context.Context, prefix string) ([]string, error) {
+	// replicas hold the same keys as their masters
+	seen := make(map[string]struct{})
+
+	for _, client := range s.client.Nodes() {
+		var cursor uint64
+
+		for {
+			entry, err := client.Do(ctx, client.B().Scan().Cursor(cursor).Match(prefix+"*").Count(rollout.ScanCount).Build()).AsScanEntry()
+			if err != nil {
+				return nil, err
+			}
+
+			for _, key := range entry.Elements {
+				seen[key] = struct{}{}
+			}
+
+			cursor = entry.Cursor
+			if cursor == 0 {
+				break
+			}
+		}
+	}
+
+	allKeys := make([]string, 0, len(seen))
+	for key := range seen {
+		allKeys = append(allKeys, key)
+	}
+	sort.Strings(allKeys)
+
+	return allKeys, nil
+}
+
+// Append implements rollout.Journal, adding the entry to the stream of the key
+func (s *Store) Append(ctx context.Context, key string, entry []byte, max int) error {
+	if max < 0 {
+		return rollout.ErrNegativeJournalMax
+	}
+
+	// MAXLEN 0 would trim every entry, rather than keep them all
+	add := s.client.B().Xadd().Key(key)
+	if max > 0 {
+		cmd := add.Maxlen().Threshold(strconv.Itoa(max)).Id("*").FieldValue().FieldValue(rollout.JournalField, string(entry)).Build()
+		return s.client.Do(ctx, cmd).Error()
+	}
+
+	return s.client.Do(ctx, add.Id("*").FieldValue().FieldValue(rollout.JournalField, string(entry)).Build()).Error()
+}
+
+// Entries implements rollout.Journal, reading the stream of the key
+func (s *Store) Entries(ctx context.Context, key string, count int) ([][]byte, error) {
+	var messages []rueidis.XRangeEntry
+	var err error
+	if count > 0 {
+		messages, err = s.client.Do(ctx, s.client.B().Xrevrange().Key(key).End("+").Start("-").Count(int64(count)).Build()).AsXRange()
+		for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+			// read from the newest
+			messages[i], messages[j] = messages[j], messages[i]
+		}
+	} else {
+		messages, err = s.client.Do(ctx, s.client.B().Xrange().Key(key).Start("-").End("+").Build()).AsXRange()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([][]byte, len(messages))
+	for i, msg := range messages {
+		entries[i] = []byte(msg.FieldValues[rollout.JournalField])
+	}
+
+	return entries, nil
+}
+
+// Publish implements rollout.Notifier
+func (s *Store) Publish(ctx context.Context, channel string, message string) error {
+	return s.client.Do(ctx, s.client.B().Publish().Channel(channel).Message(message).Build()).Error()
+}
+
+// Subscribe implements rollout.Notifier, returning once Redis has confirmed the subscription and
+// resubscribing in the background whenever the connection is lost until the context is cancelled
+func (s *Store) Subscribe(ctx context.Context, channel string) (<-chan string, error) {
+	messages := make(chan string)
+
+	client, closed, err := s.subscribe(ctx, channel, messages)
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		defer close(messages)
+
+		for {
+			select {
+			case <-ctx.Done():
+				client.Close()
+				drain(closed)
+				return
+			case <-closed:
+				client.Close()
+				drain(closed)
+			}
+
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(resubscribeDelay):
+				}
+
+				if client, closed, err = s.subscribe(ctx, channel, messages); err == nil {
+					break
+				}
+			}
+		}
+	}()
+
+	return messages, nil
+}
+
+// subscribe subscribes to the channel on a dedicated connection, forwarding its messages until
+// the returned error channel is closed
+func (s *Store) subscribe(ctx context.Context, channel string, messages chan<- string) (rueidis.DedicatedClient, <-chan error, error) {
+	client, _ := s.client.Dedicate()
+
+	closed := client.SetPubSubHooks(rueidis.PubSubHooks{
+		OnMessage: func(msg rueidis.PubSubMessage) {
+			select {
+			case messages <- msg.Message:
+			case <-ctx.Done():
+			}
+		},
+	})
+
+	if err := client.Do(ctx, client.B().Subscribe().Channel(channel).Build()).Error(); err != nil {
+		client.Close()
+		drain(closed)
+		return nil, nil, err
+	}
+
+	return client, closed, nil
+}
+
+// drain waits for a connection's pub/sub hooks to stop being called
+func drain(closed <-chan error) {
+	for range closed {
+	}
+}
@@ -0,0 +1,337 @@
+package rollout
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/vmihailenco/msgpack/v4"
+	"golang.org/x/mod/semver"
+)
+
+// Attributes describe what a feature is evaluated for, e.g. the plan tier, country or seat count of a team,
+// which targeting rules are matched against. Values are strings, numbers or booleans.
+type Attributes map[string]interface{}
+
+// Operator names how a clause compares an attribute with its values
+type Operator string
+
+const (
+	// OpEquals matches attributes equal to the value
+	OpEquals Operator = "eq"
+
+	// OpIn matches attributes equal to any of the values
+	OpIn Operator = "in"
+
+	// OpGreaterThan matches numeric attributes greater than the value
+	OpGreaterThan Operator = "gt"
+
+	// OpGreaterThanOrEqual matches numeric attributes greater than or equal to the value
+	OpGreaterThanOrEqual Operator = "gte"
+
+	// OpLessThan matches numeric attributes less than the value
+	OpLessThan Operator = "lt"
+
+	// OpLessThanOrEqual matches numeric attributes less than or equal to the value
+	OpLessThanOrEqual Operator = "lte"
+
+	// OpBetween matches numeric attributes within the two values, inclusive
+	OpBetween Operator = "between"
+
+	// OpSemverEquals matches semantic versions equal to the value
+	OpSemverEquals Operator = "semver_eq"
+
+	// OpSemverGreaterThan matches semantic versions greater than the value
+	OpSemverGreaterThan Operator = "semver_gt"
+
+	// OpSemverLessThan matches semantic versions less than the value
+	OpSemverLessThan Operator = "semver_lt"
+
+	// OpMatches matches attributes matching the regular expression value
+	OpMatches Operator = "matches"
+)
+
+// Clause compares an attribute with the values using the operator, optionally negated.
+// Clauses never match attributes which are missing, whether negated or not.
+type Clause struct {
+	Attribute string
+	Operator  Operator
+	Values    []string
+	Negate    bool
+
+	re *regexp.Regexp // the compiled value of OpMatches clauses
+}
+
+// Rule activates a feature for everything with attributes matching all of its clauses
+type Rule []Clause
+
+// ParseClause parses a clause formatted as `attribute [not] operator values`, e.g. `plan in pro,enterprise`.
+// Values are separated by commas, except for the regular expression of OpMatches.
+func ParseClause(str string) (Clause, error) {
+	fields := strings.Fields(str)
+	if len(fields) < 3 {
+		return Clause{}, fmt.Errorf("invalid clause %q, expected `attribute [not] operator values`", str)
+	}
+
+	clause := Clause{Attribute: fields[0]}
+	fields = fields[1:]
+	if fields[0] == "not" {
+		clause.Negate = true
+		fields = fields[1:]
+	}
+	if len(fields) < 2 {
+		return Clause{}, fmt.Errorf("invalid clause %q, expected `attribute [not] operator values`", str)
+	}
+
+	clause.Operator = Operator(fields[0])
+	values := strings.Join(fields[1:], " ")
+	if clause.Operator == OpMatches {
+		clause.Values = []string{values}
+	} else {
+		clause.Values = strings.Split(values, ",")
+	}
+
+	if err := clause.compile(); err != nil {
+		return Clause{}, err
+	}
+
+	return clause, nil
+}
+
+// String formats the clause the way ParseClause parses it
+func (c Clause) String() string {
+	operator := string(c.Operator)
+	if c.Negate {
+		operator = "not " + operator
+	}
+
+	return c.Attribute + " " + operator + " " + strings.Join(c.Values, ",")
+}
+
+// String formats the rule as its clauses joined by &&
+func (r Rule) String() string {
+	clauses := make([]string, len(r))
+	for i, clause := range r {
+		clauses[i] = clause.String()
+	}
+
+	return strings.Join(clauses, " && ")
+}
+
+// EncodeMsgpack implements msgpack.CustomEncoder
+func (c Clause) EncodeMsgpack(enc *msgpack.Encoder) error {
+	return encodeTuple(enc, c.Attribute, c.Operator, c.Values, c.Negate)
+}
+
+// DecodeMsgpack implements msgpack.CustomDecoder
+func (c *Clause) DecodeMsgpack(dec *msgpack.Decoder) error {
+	if err := decodeTuple(dec, &c.Attribute, &c.Operator, &c.Values, &c.Negate); err != nil {
+		return err
+	}
+
+	// clauses which can't be compiled, e.g. with operators added by newer versions, never match
+	_ = c.compile()
+
+	return nil
+}
+
+// compile validates the clause, compiling the regular expression of OpMatches clauses
+func (c *Clause) compile() error {
+	if c.Attribute == "" {
+		return errors.New("clause is missing the attribute")
+	}
+
+	switch c.Operator {
+	case OpEquals, OpGreaterThan, OpGreaterThanOrEqual, OpLessThan, OpLessThanOrEqual,
+		OpSemverEquals, OpSemverGreaterThan, OpSemverLessThan, OpMatches:
+		if len(c.Values) != 1 {
+			return fmt.Errorf("operator %q requires a single value", c.Operator)
+		}
+	case OpBetween:
+		if len(c.Values) != 2 {
+			return fmt.Errorf("operator %q requires two values", c.Operator)
+		}
+	case OpIn:
+		if len(c.Values) == 0 {
+			return fmt.Errorf("operator %q requires values", c.Operator)
+		}
+	default:
+		return fmt.Errorf("unknown operator %q", c.Operator)
+	}
+
+	switch c.Operator {
+	case OpGreaterThan, OpGreaterThanOrEqual, OpLessThan, OpLessThanOrEqual, OpBetween:
+		for _, value := range c.Values {
+			if _, err := strconv.ParseFloat(value, 64); err != nil {
+				return fmt.Errorf("operator %q requires numeric values, got %q", c.Operator, value)
+			}
+		}
+	case OpSemverEquals, OpSemverGreaterThan, OpSemverLessThan:
+		if !semver.IsValid(canonicalVersion(c.Values[0])) {
+			return fmt.Errorf("operator %q requires a semantic version, got %q", c.Operator, c.Values[0])
+		}
+	case OpMatches:
+		re, err := regexp.Compile(c.Values[0])
+		if err != nil {
+			return err
+		}
+		c.re = re
+	}
+
+	return nil
+}
+
+// matches returns whether the attributes match the clause
+func (c *Clause) matches(attributes Attributes) bool {
+	value, ok := attributes[c.Attribute]
+	if !ok || value == nil {
+		return false
+	}
+
+	matched, ok := c.compare(value)
+	if !ok {
+		// attributes of the wrong type, and invalid clauses, never match
+		return false
+	}
+
+	return matched != c.Negate
+}
+
+// compare compares the attribute value with the clause, reporting whether the comparison was possible
+func (c *Clause) compare(value interface{}) (bool, bool) {
+	switch c.Operator {
+	case OpEquals, OpIn:
+		str := fmt.Sprint(value)
+		for _, v := range c.Values {
+			if v == str {
+				return true, true
+			}
+		}
+		return false, true
+
+	case OpGreaterThan, OpGreaterThanOrEqual, OpLessThan, OpLessThanOrEqual, OpBetween:
+		n, ok := number(value)
+		if !ok || len(c.Values) == 0 {
+			return false, false
+		}
+		bound, _ := strconv.ParseFloat(c.Values[0], 64)
+
+		switch c.Operator {
+		case OpGreaterThan:
+			return n > bound, true
+		case OpGreaterThanOrEqual:
+			return n >= bound, true
+		case OpLessThan:
+			return n < bound, true
+		case OpLessThanOrEqual:
+			return n <= bound, true
+		default:
+			if len(c.Values) != 2 {
+				return false, false
+			}
+			upper, _ := strconv.ParseFloat(c.Values[1], 64)
+			return n >= bound && n <= upper, true
+		}
+
+	case OpSemverEquals, OpSemverGreaterThan, OpSemverLessThan:
+		str, ok := value.(string)
+		if !ok || len(c.Values) == 0 || !semver.IsValid(canonicalVersion(str)) {
+			return false, false
+		}
+		cmp := semver.Compare(canonicalVersion(str), canonicalVersion(c.Values[0]))
+
+		switch c.Operator {
+		case OpSemverEquals:
+			return cmp == 0, true
+		case OpSemverGreaterThan:
+			return cmp > 0, true
+		default:
+			return cmp < 0, true
+		}
+
+	case OpMatches:
+		if c.re == nil {
+			return false, false
+		}
+		return c.re.MatchString(fmt.Sprint(value)), true
+
+	default:
+		return false, false
+	}
+}
+
+// matches returns whether the attributes match every clause of the rule
+func (r Rule) matches(attributes Attributes) bool {
+	if len(r) == 0 {
+		return false
+	}
+
+	for i := range r {
+		if !r[i].matches(attributes) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// validate returns an error when the rule has no clauses or any of them is invalid
+func (r Rule) validate() error {
+	if len(r) == 0 {
+		return errors.New("rule has no clauses")
+	}
+
+	for i := range r {
+		if err := r[i].compile(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// number converts numeric attribute values, including numeric strings, to a float
+func number(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int8:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint8:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	case string:
+		n, err := strconv.ParseFloat(v, 64)
+		return n, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// canonicalVersion prefixes semantic versions with the v the semver package requires
+func canonicalVersion(version string) string {
+	if strings.HasPrefix(version, "v") {
+		return version
+	}
+
+	return "v" + version
+}
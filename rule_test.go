@@ -0,0 +1,112 @@
+package rollout
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vmihailenco/msgpack/v4"
+)
+
+func TestParseClause(t *testing.T) {
+	clause, err := ParseClause("plan in pro,enterprise")
+	assert.NoError(t, err)
+	assert.Equal(t, Clause{Attribute: "plan", Operator: OpIn, Values: []string{"pro", "enterprise"}}, clause)
+	assert.Equal(t, "plan in pro,enterprise", clause.String())
+
+	clause, err = ParseClause("country not eq US")
+	assert.NoError(t, err)
+	assert.True(t, clause.Negate)
+	assert.Equal(t, "country not eq US", clause.String())
+
+	// regular expressions aren't split
+	clause, err = ParseClause("email matches ^[a-z]+@(example|test)\\.com$")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{`^[a-z]+@(example|test)\.com$`}, clause.Values)
+
+	for str, msg := range map[string]string{
+		"plan in":                  "invalid clause \"plan in\", expected `attribute [not] operator values`",
+		"plan not in":              "invalid clause \"plan not in\", expected `attribute [not] operator values`",
+		"plan like pro":            `unknown operator "like"`,
+		"plan eq pro,enterprise":   `operator "eq" requires a single value`,
+		"seats between 1":          `operator "between" requires two values`,
+		"seats gte many":           `operator "gte" requires numeric values, got "many"`,
+		"version semver_gt latest": `operator "semver_gt" requires a semantic version, got "latest"`,
+		"email matches [":          "error parsing regexp: missing closing ]: `[`",
+	} {
+		_, err := ParseClause(str)
+		assert.EqualError(t, err, msg, str)
+	}
+}
+
+func TestClauseMatches(t *testing.T) {
+	attributes := Attributes{
+		"plan":    "pro",
+		"country": "US",
+		"seats":   50,
+		"revenue": "1234.5",
+		"version": "1.10.0",
+		"email":   "jane@example.com",
+		"beta":    true,
+	}
+
+	for str, matches := range map[string]bool{
+		"plan eq pro":                       true,
+		"plan eq free":                      false,
+		"plan in free,pro":                  true,
+		"plan not in free,pro":              false,
+		"country not eq CA":                 true,
+		"beta eq true":                      true,
+		"seats eq 50":                       true,
+		"seats gt 49":                       true,
+		"seats gt 50":                       false,
+		"seats gte 50":                      true,
+		"seats lt 50":                       false,
+		"seats lte 50":                      true,
+		"seats between 10,100":              true,
+		"seats between 51,100":              false,
+		"revenue gt 1000":                   true,
+		"plan gt 1":                         false,
+		"version semver_gt 1.9.0":           true,
+		"version semver_lt v1.9.0":          false,
+		"version semver_eq 1.10.0":          true,
+		"version semver_gt 1.10.0-rc.1":     true,
+		"seats semver_eq 50.0.0":            false,
+		"email matches @example\\.com$":     true,
+		"email not matches @example\\.com$": false,
+		"missing eq anything":               false,
+		"missing not eq anything":           false,
+		"missing not matches .*":            false,
+	} {
+		clause, err := ParseClause(str)
+		assert.NoError(t, err, str)
+		assert.Equal(t, matches, clause.matches(attributes), str)
+	}
+}
+
+func TestRule(t *testing.T) {
+	plan, _ := ParseClause("plan in pro,enterprise")
+	seats, _ := ParseClause("seats gte 50")
+	rule := Rule{plan, seats}
+	assert.NoError(t, rule.validate())
+	assert.Equal(t, "plan in pro,enterprise && seats gte 50", rule.String())
+
+	assert.True(t, rule.matches(Attributes{"plan": "pro", "seats": 50}))
+	assert.False(t, rule.matches(Attributes{"plan": "pro", "seats": 10}))
+	assert.False(t, rule.matches(Attributes{"plan": "pro"}))
+
+	assert.EqualError(t, Rule{}.validate(), "rule has no clauses")
+	assert.False(t, Rule{}.matches(Attributes{}))
+	assert.EqualError(t, Rule{{Operator: OpEquals, Values: []string{"pro"}}}.validate(), "clause is missing the attribute")
+
+	// regular expressions are compiled when decoded
+	email, _ := ParseClause("email matches @example\\.com$")
+	data, err := msgpack.Marshal(Rule{email, {Attribute: "plan", Operator: "like", Values: []string{"pro"}}})
+	assert.NoError(t, err)
+
+	var out Rule
+	assert.NoError(t, msgpack.Unmarshal(data, &out))
+	assert.True(t, out[0].matches(Attributes{"email": "jane@example.com"}))
+
+	// unknown operators never match
+	assert.False(t, out[1].matches(Attributes{"plan": "pro"}))
+}
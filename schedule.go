@@ -0,0 +1,201 @@
+package rollout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v4"
+)
+
+// ScheduleOperation names the change a scheduled change makes to a feature
+type ScheduleOperation string
+
+const (
+	// ScheduleActivate globally activates the feature
+	ScheduleActivate ScheduleOperation = "activate"
+
+	// ScheduleDeactivate deactivates the feature for everyone, the same as Manager.Deactivate
+	ScheduleDeactivate ScheduleOperation = "deactivate"
+
+	// SchedulePercentage activates the feature for a percentage of teams
+	SchedulePercentage ScheduleOperation = "percentage"
+)
+
+// ScheduledChange is a change made to a feature at a time. Changes are applied to the stored feature whenever
+// it's evaluated, so they take effect on time without anyone or anything having to make them.
+type ScheduledChange struct {
+	At          time.Time
+	Operation   ScheduleOperation
+	BasisPoints uint16 // the rollout of SchedulePercentage changes, in hundredths of a percent
+}
+
+// String describes the change, e.g. `percentage 12.5%`
+func (c ScheduledChange) String() string {
+	if c.Operation == SchedulePercentage {
+		return fmt.Sprintf("%s %g%%", c.Operation, float64(c.BasisPoints)/100)
+	}
+
+	return string(c.Operation)
+}
+
+// EncodeMsgpack implements msgpack.CustomEncoder
+func (c ScheduledChange) EncodeMsgpack(enc *msgpack.Encoder) error {
+	return encodeTuple(enc, unixNano(c.At), c.Operation, c.BasisPoints)
+}
+
+// DecodeMsgpack implements msgpack.CustomDecoder
+func (c *ScheduledChange) DecodeMsgpack(dec *msgpack.Decoder) error {
+	var at int64
+	if err := decodeTuple(dec, &at, &c.Operation, &c.BasisPoints); err != nil {
+		return err
+	}
+	c.At = fromUnixNano(at)
+
+	return nil
+}
+
+// validate returns an error when the change can't be applied
+func (c ScheduledChange) validate() error {
+	if c.At.IsZero() {
+		return errors.New("scheduled change is missing the time")
+	}
+
+	switch c.Operation {
+	case ScheduleActivate, ScheduleDeactivate:
+		return nil
+	case SchedulePercentage:
+		if c.BasisPoints > MaxBasisPoints {
+			return fmt.Errorf("scheduled percentage %g%% is over 100%%", float64(c.BasisPoints)/100)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown scheduled operation %q", c.Operation)
+	}
+}
+
+// equal returns whether the changes make the same change at the same time
+func (c ScheduledChange) equal(other ScheduledChange) bool {
+	return c.At.Equal(other.At) && c.Operation == other.Operation && c.BasisPoints == other.BasisPoints
+}
+
+// apply makes the change to the feature
+func (c ScheduledChange) apply(f *Feature) {
+	switch c.Operation {
+	case ScheduleActivate:
+		f.activate()
+	case ScheduleDeactivate:
+		f.deactivate()
+	case SchedulePercentage:
+		f.activateBasisPoints(c.BasisPoints)
+	}
+}
+
+// scheduleChange adds the change to the schedule, after the changes scheduled at the same time
+func (f *Feature) scheduleChange(change ScheduledChange) {
+	i := sort.Search(len(f.schedule), func(i int) bool {
+		return f.schedule[i].At.After(change.At)
+	})
+
+	schedule := append(f.schedule[:i:i], change)
+	f.schedule = append(schedule, f.schedule[i:]...)
+}
+
+// unscheduleChange removes the first scheduled change equal to the given one, reporting whether there was one
+func (f *Feature) unscheduleChange(change ScheduledChange) bool {
+	for i, c := range f.schedule {
+		if c.equal(change) {
+			f.schedule = append(f.schedule[:i:i], f.schedule[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+// due returns the number of scheduled changes due at the time
+func (f *Feature) due(now time.Time) int {
+	return sort.Search(len(f.schedule), func(i int) bool {
+		return f.schedule[i].At.After(now)
+	})
+}
+
+// applySchedule makes the changes due at the time, in the order they were scheduled in, removing them from the schedule
+func (f *Feature) applySchedule(now time.Time) {
+	n := f.due(now)
+	for _, change := range f.schedule[:n] {
+		change.apply(f)
+	}
+
+	f.schedule = f.schedule[n:]
+	if len(f.schedule) == 0 {
+		f.schedule = nil
+	}
+}
+
+// ScheduleActivation globally activates the feature at the time
+func (m *Manager) ScheduleActivation(feature *Feature, at time.Time) error {
+	return m.ScheduleActivationContext(context.Background(), feature, at)
+}
+
+// ScheduleActivationContext globally activates the feature at the time, bounded by the context
+func (m *Manager) ScheduleActivationContext(ctx context.Context, feature *Feature, at time.Time) error {
+	return m.ScheduleChangeContext(ctx, feature, ScheduledChange{At: at, Operation: ScheduleActivate})
+}
+
+// ScheduleDeactivation deactivates the feature for everyone at the time
+func (m *Manager) ScheduleDeactivation(feature *Feature, at time.Time) error {
+	return m.ScheduleDeactivationContext(context.Background(), feature, at)
+}
+
+// ScheduleDeactivationContext deactivates the feature for everyone at the time, bounded by the context
+func (m *Manager) ScheduleDeactivationContext(ctx context.Context, feature *Feature, at time.Time) error {
+	return m.ScheduleChangeContext(ctx, feature, ScheduledChange{At: at, Operation: ScheduleDeactivate})
+}
+
+// ScheduleBasisPoints activates the feature for hundredths of a percent of teams at the time
+func (m *Manager) ScheduleBasisPoints(feature *Feature, at time.Time, basisPoints uint16) error {
+	return m.ScheduleBasisPointsContext(context.Background(), feature, at, basisPoints)
+}
+
+// ScheduleBasisPointsContext activates the feature for hundredths of a percent of teams at the time, bounded by the context
+func (m *Manager) ScheduleBasisPointsContext(ctx context.Context, feature *Feature, at time.Time, basisPoints uint16) error {
+	return m.ScheduleChangeContext(ctx, feature, ScheduledChange{At: at, Operation: SchedulePercentage, BasisPoints: basisPoints})
+}
+
+// ScheduleChange schedules the change to the feature. Changes scheduled at the same time are made in the order
+// they were scheduled in, and changes scheduled in the past are made immediately.
+func (m *Manager) ScheduleChange(feature *Feature, change ScheduledChange) error {
+	return m.ScheduleChangeContext(context.Background(), feature, change)
+}
+
+// ScheduleChangeContext schedules the change to the feature, bounded by the context
+func (m *Manager) ScheduleChangeContext(ctx context.Context, feature *Feature, change ScheduledChange) error {
+	if err := change.validate(); err != nil {
+		return err
+	}
+
+	return m.update(ctx, feature, operation("schedule-change", change), func(f *Feature) {
+		f.scheduleChange(change)
+	})
+}
+
+// UnscheduleChange cancels the scheduled change of the feature, e.g. one of Feature.Schedule,
+// returning an error when no such change is scheduled
+func (m *Manager) UnscheduleChange(feature *Feature, change ScheduledChange) error {
+	return m.UnscheduleChangeContext(context.Background(), feature, change)
+}
+
+// UnscheduleChangeContext cancels the scheduled change of the feature, returning an error when no such change is scheduled,
+// bounded by the context. The change is matched by its time and what it does rather than its index, which changes as
+// changes are scheduled and made.
+func (m *Manager) UnscheduleChangeContext(ctx context.Context, feature *Feature, change ScheduledChange) error {
+	return m.tryUpdate(ctx, feature, operation("unschedule-change", change), func(f *Feature) error {
+		if !f.unscheduleChange(change) {
+			return fmt.Errorf("feature %q has no %s change scheduled at %s", f.Name(), change, change.At.Format(time.RFC3339))
+		}
+		return nil
+	})
+}
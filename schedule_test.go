@@ -0,0 +1,109 @@
+package rollout
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScheduledChangeString(t *testing.T) {
+	assert.Equal(t, "activate", ScheduledChange{Operation: ScheduleActivate}.String())
+	assert.Equal(t, "percentage 12.5%", ScheduledChange{Operation: SchedulePercentage, BasisPoints: 1250}.String())
+}
+
+func TestFeatureSchedule(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	f := NewFeature("example")
+	f.activateTeam(1)
+	f.scheduleChange(ScheduledChange{At: now.Add(2 * time.Hour), Operation: ScheduleDeactivate})
+	f.scheduleChange(ScheduledChange{At: now, Operation: SchedulePercentage, BasisPoints: 2500})
+	f.scheduleChange(ScheduledChange{At: now.Add(time.Hour), Operation: ScheduleActivate})
+
+	schedule := f.Schedule()
+	assert.Len(t, schedule, 3)
+	assert.Equal(t, SchedulePercentage, schedule[0].Operation)
+	assert.Equal(t, ScheduleActivate, schedule[1].Operation)
+	assert.Equal(t, ScheduleDeactivate, schedule[2].Operation)
+
+	// nothing is due yet, so the feature itself is the state
+	assert.Same(t, f, f.at(now.Add(-time.Second)))
+
+	state := f.at(now)
+	assert.Equal(t, uint16(2500), state.BasisPoints())
+	assert.Len(t, state.Schedule(), 2)
+
+	state = f.at(now.Add(time.Hour))
+	assert.True(t, state.isActive())
+
+	state = f.at(now.Add(3 * time.Hour))
+	assert.False(t, state.isTeamActive(1, false))
+	assert.Empty(t, state.Schedule())
+
+	// the feature itself is left untouched
+	assert.Equal(t, uint16(0), f.BasisPoints())
+	assert.True(t, f.isTeamActive(1, false))
+
+	assert.True(t, f.unscheduleChange(ScheduledChange{At: now.Add(time.Hour), Operation: ScheduleActivate}))
+	assert.False(t, f.unscheduleChange(ScheduledChange{At: now.Add(time.Hour), Operation: ScheduleActivate}))
+	assert.False(t, f.unscheduleChange(ScheduledChange{At: now, Operation: SchedulePercentage, BasisPoints: 5000}))
+	assert.Len(t, f.Schedule(), 2)
+	assert.Equal(t, ScheduleDeactivate, f.Schedule()[1].Operation)
+}
+
+func TestManagerSchedule(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	manager := NewManagerWithStore(NewMemoryStore(), mockKeyPrefix, true, WithCache(time.Hour, time.Hour))
+	manager.now = func() time.Time { return now }
+
+	f := NewFeature("example")
+	assert.NoError(t, manager.ScheduleActivation(f, now.Add(time.Hour)))
+	assert.NoError(t, manager.ScheduleBasisPoints(f, now.Add(2*time.Hour), 1000))
+	assert.NoError(t, manager.ScheduleDeactivation(f, now.Add(3*time.Hour)))
+	assert.EqualError(t, manager.ScheduleBasisPoints(f, now, 10001), "scheduled percentage 100.01% is over 100%")
+	assert.EqualError(t, manager.ScheduleChange(f, ScheduledChange{At: now, Operation: "pause"}), `unknown scheduled operation "pause"`)
+	assert.EqualError(t, manager.ScheduleActivation(f, time.Time{}), "scheduled change is missing the time")
+
+	active, err := manager.IsActive(f)
+	assert.NoError(t, err)
+	assert.False(t, active)
+
+	// scheduled changes take effect at check time, even for cached features
+	now = now.Add(time.Hour)
+	active, err = manager.IsActive(f)
+	assert.NoError(t, err)
+	assert.True(t, active)
+
+	// changes are made on top of the due scheduled changes
+	assert.NoError(t, manager.ActivateTeam(1, f))
+	assert.Len(t, f.Schedule(), 2)
+
+	features, err := manager.List()
+	assert.NoError(t, err)
+	assert.True(t, features[0].isActive())
+	assert.Len(t, features[0].Schedule(), 2)
+
+	now = now.Add(time.Hour)
+	results, err := manager.IsActiveMulti(f)
+	assert.NoError(t, err)
+	assert.Equal(t, []bool{false}, results)
+
+	active, err = manager.IsTeamActive(1, f)
+	assert.NoError(t, err)
+	assert.True(t, active)
+
+	// cancelled changes aren't made, even when changes are scheduled before them in the meantime
+	deactivation := ScheduledChange{At: now.Add(time.Hour), Operation: ScheduleDeactivate}
+	other := NewManagerWithStore(manager.store, mockKeyPrefix, true)
+	other.now = manager.now
+	assert.NoError(t, other.ScheduleActivation(NewFeature("example"), now.Add(time.Minute)))
+	assert.NoError(t, manager.UnscheduleChange(f, deactivation))
+	assert.Len(t, f.Schedule(), 1)
+	assert.EqualError(t, manager.UnscheduleChange(f, deactivation), `feature "example" has no deactivate change scheduled at 2020-01-01T03:00:00Z`)
+	now = now.Add(time.Hour)
+
+	active, err = manager.IsTeamActive(1, f)
+	assert.NoError(t, err)
+	assert.True(t, active)
+}
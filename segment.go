@@ -0,0 +1,464 @@
+package rollout
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v4"
+)
+
+// Segment is a named group of teams, e.g. beta customers, which features can be activated for
+// at once. Membership changes apply to every feature the segment is activated for.
+type Segment struct {
+	name    string
+	teamIDs intSet // the teams in the segment
+}
+
+func newSegment(name string) *Segment {
+	return &Segment{name: name}
+}
+
+// EncodeMsgpack implements msgpack.CustomEncoder
+func (s *Segment) EncodeMsgpack(enc *msgpack.Encoder) error {
+	return enc.Encode(s.teamIDs)
+}
+
+// DecodeMsgpack implements msgpack.CustomDecoder
+func (s *Segment) DecodeMsgpack(dec *msgpack.Decoder) error {
+	return dec.Decode(&s.teamIDs)
+}
+
+// Name returns the name of the segment
+func (s *Segment) Name() string {
+	return s.name
+}
+
+// TeamIDs returns the ids of the teams in the segment, in ascending order
+func (s *Segment) TeamIDs() []int64 {
+	teamIDs := make([]int64, 0, len(s.teamIDs))
+	for teamID := range s.teamIDs {
+		teamIDs = append(teamIDs, teamID)
+	}
+	sort.Slice(teamIDs, func(i, j int) bool { return teamIDs[i] < teamIDs[j] })
+
+	return teamIDs
+}
+
+func (s *Segment) has(teamID int64) bool {
+	_, ok := s.teamIDs[teamID]
+	return ok
+}
+
+func (s *Segment) addTeams(teamIDs ...int64) {
+	if s.teamIDs == nil {
+		s.teamIDs = make(intSet, len(teamIDs))
+	}
+
+	for _, teamID := range teamIDs {
+		s.teamIDs[teamID] = struct{}{}
+	}
+}
+
+func (s *Segment) removeTeams(teamIDs ...int64) {
+	for _, teamID := range teamIDs {
+		delete(s.teamIDs, teamID)
+	}
+}
+
+// segmentKeyPrefix is the prefix of the segment keys, which doesn't overlap with the feature keys
+func (m *Manager) segmentKeyPrefix() string {
+	return m.keyPrefix + "-segment:"
+}
+
+func (m *Manager) segmentKeyName(name string) string {
+	return m.segmentKeyPrefix() + name
+}
+
+// segmentChannelName is the pub/sub channel announcing the names of changed segments
+func (m *Manager) segmentChannelName() string {
+	return m.keyPrefix + ":segment-changes"
+}
+
+// decodeSegment returns the segment with the given data, nil data meaning the segment isn't persisted
+func (m *Manager) decodeSegment(name string, data []byte) (*Segment, error) {
+	segment := newSegment(name)
+	if data == nil {
+		return segment, nil
+	}
+
+	if err := msgpack.Unmarshal(data, segment); err != nil {
+		return nil, err
+	}
+
+	return segment, nil
+}
+
+// loadSegments returns the segments the given feature states are active for, keyed by name,
+// which must not be modified as they may be shared with other callers
+func (m *Manager) loadSegments(ctx context.Context, states ...*Feature) (map[string]*Segment, error) {
+	var segments map[string]*Segment
+	var missing []string
+	var keys []string
+	for _, state := range states {
+		for name := range state.segments {
+			if _, ok := segments[name]; ok {
+				continue
+			}
+			if segments == nil {
+				segments = make(map[string]*Segment)
+			}
+
+			segment, ok := m.cachedSegment(name)
+			segments[name] = segment
+			if !ok {
+				missing = append(missing, name)
+				keys = append(keys, m.segmentKeyName(name))
+			}
+		}
+	}
+
+	if len(keys) == 0 {
+		return segments, nil
+	}
+
+	// retrieve segments from the store
+	fetchedAt := m.now()
+	val, err := m.store.MGet(ctx, keys...)
+	if err != nil {
+		for _, name := range missing {
+			if segments[name], err = m.fallbackSegment(name, err); err != nil {
+				return nil, err
+			}
+		}
+
+		return segments, nil
+	}
+
+	for j, name := range missing {
+		if segments[name], err = m.fetchedSegment(name, val[j], fetchedAt); err != nil {
+			return nil, err
+		}
+	}
+
+	return segments, nil
+}
+
+// cachedSegment returns the segment from the snapshot when polling, otherwise from the cache
+// when enabled, refreshing it in the background when stale
+func (m *Manager) cachedSegment(name string) (*Segment, bool) {
+	if snap := m.snapshot.Load(); snap != nil {
+		if segment, ok := snap.segments[name]; ok {
+			return segment, true
+		}
+
+		// segment isn't in the store, so has no teams
+		return newSegment(name), true
+	}
+
+	if m.cache == nil {
+		return nil, false
+	}
+
+	segment, refresh, ok := m.cache.get(m.segmentKeyName(name), m.now())
+	if !ok {
+		return nil, false
+	}
+	if refresh {
+		go m.refreshSegment(name)
+	}
+
+	return segment.(*Segment), true
+}
+
+// fetchedSegment decodes the data fetched from the store for the segment, caching it when enabled
+func (m *Manager) fetchedSegment(name string, data []byte, fetchedAt time.Time) (*Segment, error) {
+	segment, err := m.decodeSegment(name, data)
+	if err != nil {
+		return nil, err
+	}
+
+	if m.cache != nil {
+		m.cache.set(m.segmentKeyName(name), segment, fetchedAt)
+	}
+	if m.fallback == FallbackLastKnown {
+		m.lastKnown.Store(m.segmentKeyName(name), segment)
+	}
+
+	return segment, nil
+}
+
+// fallbackSegment returns the segment to evaluate when the store couldn't be reached
+func (m *Manager) fallbackSegment(name string, err error) (*Segment, error) {
+	switch m.fallback {
+	case FallbackLastKnown:
+		if segment, ok := m.lastKnown.Load(m.segmentKeyName(name)); ok {
+			return segment.(*Segment), nil
+		}
+		return newSegment(name), nil
+
+	case FallbackDefault, FallbackClosed:
+		return newSegment(name), nil
+
+	default:
+		return nil, err
+	}
+}
+
+// refreshSegment fetches the segment from the store in the background to replace the stale cached copy
+func (m *Manager) refreshSegment(name string) {
+	key := m.segmentKeyName(name)
+	defer m.cache.done(key)
+
+	fetchedAt := m.now()
+	data, err := m.store.Get(context.Background(), key)
+	if err != nil {
+		// keep serving the stale segment, the next evaluation retries the refresh
+		return
+	}
+
+	// a segment that can't be decoded is fetched again by the next evaluation once too stale
+	_, _ = m.fetchedSegment(name, data, fetchedAt)
+}
+
+// segmentUpdated caches the segment written to the store and announces the change
+func (m *Manager) segmentUpdated(ctx context.Context, segment *Segment) error {
+	if m.cache != nil {
+		m.cache.set(m.segmentKeyName(segment.name), segment, m.now())
+	}
+	m.segmentSnapshotted(segment)
+
+	if m.notifier != nil {
+		if err := m.notifier.Publish(ctx, m.segmentChannelName(), segment.name); err != nil {
+			return &NotifyError{Segment: segment.name, Err: err}
+		}
+	}
+
+	return nil
+}
+
+// segmentChanged drops the segment announced as changed by another Manager from the cache,
+// fetching it into the snapshot when polling
+func (m *Manager) segmentChanged(ctx context.Context, name string) {
+	if m.cache != nil {
+		m.cache.delete(m.segmentKeyName(name))
+	}
+
+	if m.snapshot.Load() != nil {
+		data, err := m.store.Get(ctx, m.segmentKeyName(name))
+		if err != nil {
+			// the next snapshot refresh picks up the change
+			return
+		}
+
+		if segment, err := m.decodeSegment(name, data); err == nil {
+			m.segmentSnapshotted(segment)
+		}
+	}
+}
+
+// teamIDsArgs returns the team ids as the arguments of an operation
+func teamIDsArgs(teamIDs []int64) []interface{} {
+	args := make([]interface{}, len(teamIDs))
+	for i, teamID := range teamIDs {
+		args[i] = teamID
+	}
+
+	return args
+}
+
+// updateSegment atomically applies the change to the segment, re-reading and retrying
+// whenever the segment was modified in the store by someone else in the meantime,
+// and records it in the history of the segment as the operation
+func (m *Manager) updateSegment(ctx context.Context, name string, operation string, change func(s *Segment)) error {
+	key := m.segmentKeyName(name)
+
+	for attempt := 0; attempt < maxUpdateAttempts; attempt++ {
+		current, err := m.store.Get(ctx, key)
+		if err != nil {
+			return err
+		}
+
+		segment, err := m.decodeSegment(name, current)
+		if err != nil {
+			return err
+		}
+		change(segment)
+
+		data, err := msgpack.Marshal(segment)
+		if err != nil {
+			return err
+		}
+
+		swapped, err := m.store.CompareAndSet(ctx, key, current, data)
+		if err != nil {
+			return err
+		}
+		if swapped {
+			// the change is made whether or not it's recorded, so it's announced either way
+			recordErr := m.record(ctx, m.segmentHistoryKeyName(name), historyRecord{
+				At:        m.now(),
+				Author:    authorFromContext(ctx),
+				Operation: operation,
+				Reason:    reasonFromContext(ctx),
+				Before:    current,
+				After:     data,
+			})
+
+			if err := m.segmentUpdated(ctx, segment); err != nil {
+				return err
+			}
+
+			return m.segmentHistoryError(name, recordErr)
+		}
+	}
+
+	return &ConflictError{Segment: name, Attempts: maxUpdateAttempts}
+}
+
+// AddSegmentTeams adds the teams to the segment, creating it when it doesn't exist
+func (m *Manager) AddSegmentTeams(name string, teamIDs ...int64) error {
+	return m.AddSegmentTeamsContext(context.Background(), name, teamIDs...)
+}
+
+// AddSegmentTeamsContext adds the teams to the segment, creating it when it doesn't exist, bounded by the context
+func (m *Manager) AddSegmentTeamsContext(ctx context.Context, name string, teamIDs ...int64) error {
+	return m.updateSegment(ctx, name, operation("add-teams", teamIDsArgs(teamIDs)...), func(s *Segment) {
+		s.addTeams(teamIDs...)
+	})
+}
+
+// RemoveSegmentTeams removes the teams from the segment
+func (m *Manager) RemoveSegmentTeams(name string, teamIDs ...int64) error {
+	return m.RemoveSegmentTeamsContext(context.Background(), name, teamIDs...)
+}
+
+// RemoveSegmentTeamsContext removes the teams from the segment, bounded by the context
+func (m *Manager) RemoveSegmentTeamsContext(ctx context.Context, name string, teamIDs ...int64) error {
+	return m.updateSegment(ctx, name, operation("remove-teams", teamIDsArgs(teamIDs)...), func(s *Segment) {
+		s.removeTeams(teamIDs...)
+	})
+}
+
+// DeleteSegment removes the segment from the store, reporting whether it was found.
+// Features the segment is activated for are no longer active for its teams.
+func (m *Manager) DeleteSegment(name string) (bool, error) {
+	return m.DeleteSegmentContext(context.Background(), name)
+}
+
+// DeleteSegmentContext removes the segment from the store, reporting whether it was found, bounded by the context
+func (m *Manager) DeleteSegmentContext(ctx context.Context, name string) (bool, error) {
+	var current []byte
+	var recordErr error
+	if m.journal != nil {
+		// read for the history, which records the segment as it was deleted
+		current, recordErr = m.store.Get(ctx, m.segmentKeyName(name))
+	}
+
+	deleted, err := m.store.Delete(ctx, m.segmentKeyName(name))
+	if err != nil {
+		return false, err
+	}
+
+	if deleted && m.journal != nil && recordErr == nil {
+		recordErr = m.record(ctx, m.segmentHistoryKeyName(name), historyRecord{
+			At:        m.now(),
+			Author:    authorFromContext(ctx),
+			Operation: operation("delete"),
+			Reason:    reasonFromContext(ctx),
+			Before:    current,
+		})
+	}
+
+	if err := m.segmentUpdated(ctx, newSegment(name)); err != nil {
+		return deleted, err
+	}
+
+	if !deleted {
+		return false, nil
+	}
+
+	return true, m.segmentHistoryError(name, recordErr)
+}
+
+// Segment returns the segment with the given name, which has no teams when it doesn't exist
+func (m *Manager) Segment(name string) (*Segment, error) {
+	return m.SegmentContext(context.Background(), name)
+}
+
+// SegmentContext returns the segment with the given name, which has no teams when it doesn't exist, bounded by the context
+func (m *Manager) SegmentContext(ctx context.Context, name string) (*Segment, error) {
+	data, err := m.store.Get(ctx, m.segmentKeyName(name))
+	if err != nil {
+		return nil, err
+	}
+
+	return m.decodeSegment(name, data)
+}
+
+// Segments returns every segment persisted under the key prefix, sorted by name
+func (m *Manager) Segments() ([]*Segment, error) {
+	return m.SegmentsContext(context.Background())
+}
+
+// SegmentsContext returns every segment persisted under the key prefix, sorted by name, bounded by the context
+func (m *Manager) SegmentsContext(ctx context.Context) ([]*Segment, error) {
+	prefix := m.segmentKeyPrefix()
+
+	keys, err := m.store.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	sort.Strings(keys)
+
+	val, err := m.store.MGet(ctx, keys...)
+	if err != nil {
+		return nil, err
+	}
+
+	segments := make([]*Segment, 0, len(keys))
+	for i, key := range keys {
+		if val[i] == nil {
+			// segment was deleted since listing the keys
+			continue
+		}
+
+		segment, err := m.decodeSegment(strings.TrimPrefix(key, prefix), val[i])
+		if err != nil {
+			return nil, err
+		}
+		segments = append(segments, segment)
+	}
+
+	return segments, nil
+}
+
+// ActivateSegment activates the feature for the teams of the segment
+func (m *Manager) ActivateSegment(name string, feature *Feature) error {
+	return m.ActivateSegmentContext(context.Background(), name, feature)
+}
+
+// ActivateSegmentContext activates the feature for the teams of the segment, bounded by the context
+func (m *Manager) ActivateSegmentContext(ctx context.Context, name string, feature *Feature) error {
+	return m.update(ctx, feature, operation("activate-segment", name), func(f *Feature) {
+		f.activateSegment(name)
+	})
+}
+
+// DeactivateSegment deactivates the feature for the teams of the segment,
+// other than the ones it's otherwise active for
+func (m *Manager) DeactivateSegment(name string, feature *Feature) error {
+	return m.DeactivateSegmentContext(context.Background(), name, feature)
+}
+
+// DeactivateSegmentContext deactivates the feature for the teams of the segment, bounded by the context
+func (m *Manager) DeactivateSegmentContext(ctx context.Context, name string, feature *Feature) error {
+	return m.update(ctx, feature, operation("deactivate-segment", name), func(f *Feature) {
+		f.deactivateSegment(name)
+	})
+}
@@ -0,0 +1,259 @@
+package rollout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vmihailenco/msgpack/v4"
+)
+
+func TestSegmentEncodeDecode(t *testing.T) {
+	in := newSegment("beta")
+	in.addTeams(1, 2, 3)
+	in.removeTeams(2)
+
+	data, err := msgpack.Marshal(in)
+	assert.NoError(t, err)
+
+	out := newSegment("beta")
+	assert.NoError(t, msgpack.Unmarshal(data, out))
+	assert.Equal(t, "beta", out.Name())
+	assert.Equal(t, []int64{1, 3}, out.TeamIDs())
+	assert.True(t, out.has(1))
+	assert.False(t, out.has(2))
+}
+
+func TestSegments(t *testing.T) {
+	store := NewMemoryStore()
+	manager := NewManagerWithStore(store, mockKeyPrefix, false)
+
+	f := NewFeature("example")
+	assert.NoError(t, manager.ActivateSegment("beta", NewFeature("example")))
+
+	// segments that don't exist have no teams
+	active, err := manager.IsTeamActive(1, f)
+	assert.NoError(t, err)
+	assert.False(t, active)
+
+	assert.NoError(t, manager.AddSegmentTeams("beta", 1, 2))
+	assert.NoError(t, manager.AddSegmentTeams("internal", 3))
+
+	segment, err := manager.Segment("beta")
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{1, 2}, segment.TeamIDs())
+
+	segments, err := manager.Segments()
+	assert.NoError(t, err)
+	assert.Len(t, segments, 2)
+	assert.Equal(t, "beta", segments[0].Name())
+	assert.Equal(t, "internal", segments[1].Name())
+
+	// segment keys aren't listed as features
+	features, err := manager.List()
+	assert.NoError(t, err)
+	assert.Len(t, features, 1)
+	assert.Equal(t, []string{"beta"}, features[0].Segments())
+
+	results, err := manager.IsTeamActiveMulti(1, f, NewFeature("other"))
+	assert.NoError(t, err)
+	assert.Equal(t, []bool{true, false}, results)
+
+	active, err = manager.IsActorActive(Team(2), f)
+	assert.NoError(t, err)
+	assert.True(t, active)
+
+	active, err = manager.IsTeamActive(3, f)
+	assert.NoError(t, err)
+	assert.False(t, active)
+
+	// membership changes apply to every feature the segment is activated for
+	assert.NoError(t, manager.ActivateSegment("beta", NewFeature("other")))
+	assert.NoError(t, manager.RemoveSegmentTeams("beta", 1))
+
+	results, err = manager.IsTeamActiveMulti(1, f, NewFeature("other"))
+	assert.NoError(t, err)
+	assert.Equal(t, []bool{false, false}, results)
+
+	results, err = manager.IsTeamActiveMulti(2, f, NewFeature("other"))
+	assert.NoError(t, err)
+	assert.Equal(t, []bool{true, true}, results)
+
+	assert.NoError(t, manager.DeactivateSegment("beta", NewFeature("other")))
+
+	active, err = manager.IsTeamActive(2, NewFeature("other"))
+	assert.NoError(t, err)
+	assert.False(t, active)
+
+	// deleting the segment removes its teams from the features
+	deleted, err := manager.DeleteSegment("beta")
+	assert.NoError(t, err)
+	assert.True(t, deleted)
+
+	active, err = manager.IsTeamActive(2, f)
+	assert.NoError(t, err)
+	assert.False(t, active)
+
+	deleted, err = manager.DeleteSegment("beta")
+	assert.NoError(t, err)
+	assert.False(t, deleted)
+}
+
+func TestSegmentsCached(t *testing.T) {
+	store := NewMemoryStore()
+	other := NewManagerWithStore(store, mockKeyPrefix, false)
+	manager := NewManagerWithStore(store, mockKeyPrefix, false, WithCache(time.Hour, time.Hour))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	assert.NoError(t, manager.Subscribe(ctx))
+
+	f := NewFeature("example")
+	assert.NoError(t, other.ActivateSegment("beta", NewFeature("example")))
+	assert.NoError(t, other.AddSegmentTeams("beta", 1))
+
+	active, err := manager.IsTeamActive(1, f)
+	assert.NoError(t, err)
+	assert.True(t, active)
+
+	// changes made elsewhere are seen without waiting for the ttl
+	assert.NoError(t, other.RemoveSegmentTeams("beta", 1))
+
+	assert.Eventually(t, func() bool {
+		active, err := manager.IsTeamActive(1, f)
+		return err == nil && !active
+	}, time.Second, time.Millisecond)
+}
+
+func TestSegmentsFallback(t *testing.T) {
+	store := &failingStore{Store: NewMemoryStore()}
+	manager := NewManagerWithStore(store, mockKeyPrefix, false, WithFallback(FallbackLastKnown))
+
+	f := NewFeature("example")
+	assert.NoError(t, manager.ActivateSegment("beta", NewFeature("example")))
+	assert.NoError(t, manager.AddSegmentTeams("beta", 1))
+
+	active, err := manager.IsTeamActive(1, f)
+	assert.NoError(t, err)
+	assert.True(t, active)
+
+	// the last known segment is served when the store can't be reached
+	store.fail = true
+
+	active, err = manager.IsTeamActive(1, f)
+	assert.NoError(t, err)
+	assert.True(t, active)
+
+	manager = NewManagerWithStore(store, mockKeyPrefix, false)
+	_, err = manager.loadSegments(context.Background(), f.blank())
+	assert.NoError(t, err)
+
+	state := NewFeature("example")
+	state.activateSegment("beta")
+	_, err = manager.loadSegments(context.Background(), state)
+	assert.EqualError(t, err, "mock error")
+}
+
+func TestSegmentsPolled(t *testing.T) {
+	store := NewMemoryStore()
+	other := NewManagerWithStore(store, mockKeyPrefix, false)
+	manager := NewManagerWithStore(store, mockKeyPrefix, false)
+
+	assert.NoError(t, other.ActivateSegment("beta", NewFeature("example")))
+	assert.NoError(t, other.AddSegmentTeams("beta", 1))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	assert.NoError(t, manager.Poll(ctx, time.Hour))
+
+	active, err := manager.IsTeamActive(1, NewFeature("example"))
+	assert.NoError(t, err)
+	assert.True(t, active)
+
+	// changes made through the manager are seen immediately
+	assert.NoError(t, manager.AddSegmentTeams("beta", 2))
+
+	active, err = manager.IsTeamActive(2, NewFeature("example"))
+	assert.NoError(t, err)
+	assert.True(t, active)
+
+	// announced changes are fetched into the snapshot
+	assert.NoError(t, manager.Subscribe(ctx))
+	assert.NoError(t, other.RemoveSegmentTeams("beta", 1))
+
+	assert.Eventually(t, func() bool {
+		active, err := manager.IsTeamActive(1, NewFeature("example"))
+		return err == nil && !active
+	}, time.Second, time.Millisecond)
+}
+
+// conflictingStore is a MemoryStore whose keys are always modified concurrently
+type conflictingStore struct {
+	*MemoryStore
+}
+
+func (s *conflictingStore) CompareAndSet(ctx context.Context, key string, old, value []byte) (bool, error) {
+	return false, nil
+}
+
+func TestSegmentsConflict(t *testing.T) {
+	manager := NewManagerWithStore(&conflictingStore{NewMemoryStore()}, mockKeyPrefix, false)
+
+	err := manager.AddSegmentTeams("beta", 1)
+	var conflict *ConflictError
+	assert.True(t, errors.As(err, &conflict))
+	assert.Equal(t, "beta", conflict.Segment)
+	assert.Empty(t, conflict.Feature)
+	assert.EqualError(t, err, fmt.Sprintf("segment %q was modified concurrently, gave up after %d attempts", "beta", maxUpdateAttempts))
+}
+
+func TestSegmentHistory(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := NewMemoryStore()
+	manager := NewManagerWithStore(store, mockKeyPrefix, false, WithHistory(DefaultHistoryLimit))
+	manager.now = func() time.Time { return now }
+
+	ctx := ContextWithReason(ContextWithAuthor(context.Background(), "alice"), "beta launch")
+	assert.NoError(t, manager.AddSegmentTeamsContext(ctx, "beta", 1, 2))
+	now = now.Add(time.Hour)
+	assert.NoError(t, manager.RemoveSegmentTeams("beta", 1))
+	_, err := manager.DeleteSegment("beta")
+	assert.NoError(t, err)
+
+	history, err := manager.SegmentHistory("beta")
+	assert.NoError(t, err)
+	assert.Len(t, history, 3)
+
+	assert.True(t, history[0].At.Equal(now.Add(-time.Hour)))
+	assert.Equal(t, "alice", history[0].Author)
+	assert.Equal(t, "add-teams 1 2", history[0].Operation)
+	assert.Equal(t, "beta launch", history[0].Reason)
+	assert.Nil(t, history[0].Before)
+	assert.Equal(t, []int64{1, 2}, history[0].After.TeamIDs())
+
+	assert.Equal(t, "remove-teams 1", history[1].Operation)
+	assert.Empty(t, history[1].Author)
+	assert.Equal(t, []int64{1, 2}, history[1].Before.TeamIDs())
+	assert.Equal(t, []int64{2}, history[1].After.TeamIDs())
+
+	assert.Equal(t, "delete", history[2].Operation)
+	assert.Equal(t, []int64{2}, history[2].Before.TeamIDs())
+	assert.Nil(t, history[2].After)
+
+	// kept apart from the history of the feature with the same name
+	features, err := manager.History(NewFeature("beta"))
+	assert.NoError(t, err)
+	assert.Empty(t, features)
+
+	// the change is made even when it can't be recorded
+	manager = NewManagerWithStore(failingJournalStore{store}, mockKeyPrefix, false, WithHistory(DefaultHistoryLimit))
+	err = manager.AddSegmentTeams("beta", 3)
+	assert.EqualError(t, err, `segment "beta" was changed, but the change couldn't be recorded in its history: unknown command 'xadd'`)
+
+	segment, err := manager.Segment("beta")
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{3}, segment.TeamIDs())
+}
@@ -0,0 +1,174 @@
+package rollout
+
+import (
+	"context"
+	"time"
+)
+
+// snapshot is an immutable set of all the features and segments in the store, keyed by name
+type snapshot struct {
+	features    map[string]*Feature
+	segments    map[string]*Segment
+	refreshedAt time.Time // when the features were fetched from the store
+}
+
+// with returns a copy of the snapshot with the state of a single feature replaced
+func (s *snapshot) with(name string, state *Feature) *snapshot {
+	features := make(map[string]*Feature, len(s.features)+1)
+	for n, f := range s.features {
+		features[n] = f
+	}
+	features[name] = state
+
+	return &snapshot{features: features, segments: s.segments, refreshedAt: s.refreshedAt}
+}
+
+// without returns a copy of the snapshot without the state of a single feature, which is then evaluated in its declared default state
+func (s *snapshot) without(name string) *snapshot {
+	features := make(map[string]*Feature, len(s.features))
+	for n, f := range s.features {
+		if n != name {
+			features[n] = f
+		}
+	}
+
+	return &snapshot{features: features, segments: s.segments, refreshedAt: s.refreshedAt}
+}
+
+// withSegment returns a copy of the snapshot with a single segment replaced
+func (s *snapshot) withSegment(segment *Segment) *snapshot {
+	segments := make(map[string]*Segment, len(s.segments)+1)
+	for n, seg := range s.segments {
+		segments[n] = seg
+	}
+	segments[segment.Name()] = segment
+
+	return &snapshot{features: s.features, segments: segments, refreshedAt: s.refreshedAt}
+}
+
+// Poll fetches every feature and segment from the store into an in-memory snapshot, which all evaluations are then answered
+// from without reading the store. The snapshot is refreshed in the background every interval until the context
+// is cancelled, with changes made through the Manager applied to it immediately, after which evaluations read the
+// store again. It returns once the first snapshot is fetched.
+func (m *Manager) Poll(ctx context.Context, interval time.Duration) error {
+	if err := m.Refresh(ctx); err != nil {
+		return err
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				// the snapshot is no longer refreshed, so it'd otherwise keep answering evaluations with stale states
+				m.snapshot.Store(nil)
+				return
+			case <-ticker.C:
+				// failures are exposed through RefreshError, the previous snapshot is kept until the next refresh
+				_ = m.Refresh(ctx)
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Refresh fetches every feature and segment from the store, atomically replacing the snapshot evaluations are answered from
+func (m *Manager) Refresh(ctx context.Context) error {
+	err := m.refreshSnapshot(ctx)
+
+	m.refreshMu.Lock()
+	m.refreshErr = err
+	m.refreshMu.Unlock()
+
+	return err
+}
+
+func (m *Manager) refreshSnapshot(ctx context.Context) error {
+	refreshedAt := m.now()
+
+	list, err := m.ListContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	features := make(map[string]*Feature, len(list))
+	for _, state := range list {
+		features[state.Name()] = state
+	}
+
+	segmentList, err := m.SegmentsContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	segments := make(map[string]*Segment, len(segmentList))
+	for _, segment := range segmentList {
+		segments[segment.Name()] = segment
+	}
+
+	m.snapshot.Store(&snapshot{features: features, segments: segments, refreshedAt: refreshedAt})
+
+	return nil
+}
+
+// LastRefresh returns when the snapshot was last successfully fetched, the zero time when not polling
+func (m *Manager) LastRefresh() time.Time {
+	if snap := m.snapshot.Load(); snap != nil {
+		return snap.refreshedAt
+	}
+
+	return time.Time{}
+}
+
+// RefreshError returns the error of the most recent snapshot refresh, nil when it succeeded
+func (m *Manager) RefreshError() error {
+	m.refreshMu.Lock()
+	defer m.refreshMu.Unlock()
+
+	return m.refreshErr
+}
+
+// snapshotted applies the state of the feature to the snapshot, when polling
+func (m *Manager) snapshotted(feature *Feature, state *Feature) {
+	for {
+		snap := m.snapshot.Load()
+		if snap == nil {
+			return
+		}
+
+		if m.snapshot.CompareAndSwap(snap, snap.with(feature.Name(), state)) {
+			return
+		}
+	}
+}
+
+// unsnapshotted removes the deleted feature from the snapshot, when polling
+func (m *Manager) unsnapshotted(feature *Feature) {
+	for {
+		snap := m.snapshot.Load()
+		if snap == nil {
+			return
+		}
+
+		if m.snapshot.CompareAndSwap(snap, snap.without(feature.Name())) {
+			return
+		}
+	}
+}
+
+// segmentSnapshotted applies the segment to the snapshot, when polling
+func (m *Manager) segmentSnapshotted(segment *Segment) {
+	for {
+		snap := m.snapshot.Load()
+		if snap == nil {
+			return
+		}
+
+		if m.snapshot.CompareAndSwap(snap, snap.withSegment(segment)) {
+			return
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package rollout
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPoll(t *testing.T) {
+	store := &failingStore{Store: NewMemoryStore()}
+	other := NewManagerWithStore(store, mockKeyPrefix, false)
+	manager := NewManagerWithStore(store, mockKeyPrefix, false)
+
+	assert.NoError(t, other.Activate(NewFeature("example1")))
+	assert.NoError(t, other.ActivateTeam(1, NewFeature("example2")))
+	assert.True(t, manager.LastRefresh().IsZero())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	assert.NoError(t, manager.Poll(ctx, time.Hour))
+	assert.False(t, manager.LastRefresh().IsZero())
+	assert.NoError(t, manager.RefreshError())
+
+	results, err := manager.IsTeamActiveMulti(1, NewFeature("example1"), NewFeature("example2"), NewFeature("example3"))
+	assert.NoError(t, err)
+	assert.Equal(t, []bool{true, true, false}, results)
+
+	// changes made elsewhere aren't seen until refreshed
+	assert.NoError(t, other.Activate(NewFeature("example3")))
+
+	active, err := manager.IsActive(NewFeature("example3"))
+	assert.NoError(t, err)
+	assert.False(t, active)
+
+	// changes made through the manager are seen immediately
+	assert.NoError(t, manager.Deactivate(NewFeature("example1")))
+
+	active, err = manager.IsActive(NewFeature("example1"))
+	assert.NoError(t, err)
+	assert.False(t, active)
+
+	// refreshing picks up the changes made elsewhere
+	assert.NoError(t, manager.Refresh(ctx))
+
+	active, err = manager.IsActive(NewFeature("example3"))
+	assert.NoError(t, err)
+	assert.True(t, active)
+
+	// failed refreshes keep the previous snapshot
+	refreshedAt := manager.LastRefresh()
+	assert.NoError(t, other.Deactivate(NewFeature("example3")))
+	store.fail = true
+	assert.EqualError(t, manager.Refresh(ctx), "mock error")
+	assert.EqualError(t, manager.RefreshError(), "mock error")
+	assert.Equal(t, refreshedAt, manager.LastRefresh())
+
+	active, err = manager.IsActive(NewFeature("example3"))
+	assert.NoError(t, err)
+	assert.True(t, active)
+
+	// polling fails when the first snapshot can't be fetched
+	assert.EqualError(t, NewManagerWithStore(store, mockKeyPrefix, false).Poll(ctx, time.Hour), "mock error")
+}
+
+func TestPollCancel(t *testing.T) {
+	store := NewMemoryStore()
+	other := NewManagerWithStore(store, mockKeyPrefix, false)
+	manager := NewManagerWithStore(store, mockKeyPrefix, false)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	assert.NoError(t, manager.Poll(ctx, time.Hour))
+	cancel()
+
+	// evaluations read the store again once polling stops
+	assert.NoError(t, other.Activate(NewFeature("example")))
+
+	assert.Eventually(t, func() bool {
+		active, err := manager.IsActive(NewFeature("example"))
+		return err == nil && active
+	}, time.Second, time.Millisecond)
+	assert.True(t, manager.LastRefresh().IsZero())
+}
+
+func TestPollSubscribe(t *testing.T) {
+	store := NewMemoryStore()
+	other := NewManagerWithStore(store, mockKeyPrefix, false)
+	manager := NewManagerWithStore(store, mockKeyPrefix, false)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	assert.NoError(t, manager.Poll(ctx, time.Hour))
+	assert.NoError(t, manager.Subscribe(ctx))
+
+	// announced changes are fetched into the snapshot
+	assert.NoError(t, other.Activate(NewFeature("example")))
+
+	assert.Eventually(t, func() bool {
+		active, err := manager.IsActive(NewFeature("example"))
+		return err == nil && active
+	}, time.Second, time.Millisecond)
+}
+
+func TestPollSubscribeDelete(t *testing.T) {
+	store := NewMemoryStore()
+	other := NewManagerWithStore(store, mockKeyPrefix, false)
+	manager := NewManagerWithStore(store, mockKeyPrefix, false)
+
+	assert.NoError(t, other.Deactivate(NewFeature("example")))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	assert.NoError(t, manager.Poll(ctx, time.Hour))
+	assert.NoError(t, manager.Subscribe(ctx))
+
+	// deleted features are evaluated in their declared default again, whether deleted elsewhere or through the manager
+	_, err := other.Delete(NewFeature("example"))
+	assert.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		active, err := manager.IsActive(NewFeature("example", WithDefault(true)))
+		return err == nil && active
+	}, time.Second, time.Millisecond)
+
+	assert.NoError(t, manager.Deactivate(NewFeature("example")))
+	_, err = manager.Delete(NewFeature("example"))
+	assert.NoError(t, err)
+
+	active, err := manager.IsActive(NewFeature("example", WithDefault(true)))
+	assert.NoError(t, err)
+	assert.True(t, active)
+}
@@ -0,0 +1,49 @@
+package rollout
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNegativeJournalMax is returned by Journal.Append when given a negative max
+var ErrNegativeJournalMax = errors.New("journal max can't be negative")
+
+// Store persists the encoded features by key, allowing the Manager to be backed by something other than redis
+type Store interface {
+	// Get returns the value of the key, or nil when the key doesn't exist
+	Get(ctx context.Context, key string) ([]byte, error)
+
+	// MGet returns the values of the keys in order, with nil for every key that doesn't exist
+	MGet(ctx context.Context, keys ...string) ([][]byte, error)
+
+	// CompareAndSet sets the key to value only when it still holds old, a nil old meaning the key must not exist.
+	// It reports whether the value was set.
+	CompareAndSet(ctx context.Context, key string, old, value []byte) (bool, error)
+
+	// Delete removes the key, reporting whether it existed
+	Delete(ctx context.Context, key string) (bool, error)
+
+	// List returns all the keys starting with the prefix
+	List(ctx context.Context, prefix string) ([]string, error)
+}
+
+// Notifier is implemented by stores able to broadcast messages to every Manager sharing the store,
+// which is used to announce feature changes
+type Notifier interface {
+	// Publish broadcasts the message to every subscriber of the channel
+	Publish(ctx context.Context, channel string, message string) error
+
+	// Subscribe delivers the messages published on the channel until the context is cancelled
+	Subscribe(ctx context.Context, channel string) (<-chan string, error)
+}
+
+// Journal is implemented by stores able to keep a capped log of entries per key, which is used to record
+// the history of changes made to features
+type Journal interface {
+	// Append adds the entry to the end of the log of the key, trimming the log to its most recent max entries,
+	// or keeping every entry when max is 0, like XADD without MAXLEN. A negative max returns ErrNegativeJournalMax.
+	Append(ctx context.Context, key string, entry []byte, max int) error
+
+	// Entries returns the most recent count entries of the log of the key, oldest first, or all of them when count is 0
+	Entries(ctx context.Context, key string, count int) ([][]byte, error)
+}
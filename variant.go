@@ -0,0 +1,158 @@
+package rollout
+
+import (
+	"context"
+	"errors"
+	"math"
+
+	"github.com/vmihailenco/msgpack/v4"
+)
+
+// Variant is a named variation of a feature, e.g. for experiments, assigned to actors in proportion to its weight
+type Variant struct {
+	Name   string
+	Weight uint32 // relative to the weights of the other variants, 0 meaning the variant isn't assigned
+}
+
+// EncodeMsgpack implements msgpack.CustomEncoder
+func (v Variant) EncodeMsgpack(enc *msgpack.Encoder) error {
+	return encodeTuple(enc, v.Name, v.Weight)
+}
+
+// DecodeMsgpack implements msgpack.CustomDecoder
+func (v *Variant) DecodeMsgpack(dec *msgpack.Decoder) error {
+	return decodeTuple(dec, &v.Name, &v.Weight)
+}
+
+// setVariant sets the weight of the variant, adding it when the feature doesn't have it
+func (f *Feature) setVariant(name string, weight uint32) {
+	for i := range f.variants {
+		if f.variants[i].Name == name {
+			f.variants[i].Weight = weight
+			return
+		}
+	}
+
+	f.variants = append(f.variants, Variant{Name: name, Weight: weight})
+}
+
+// removeVariant removes the variant along with its payload
+func (f *Feature) removeVariant(name string) {
+	delete(f.payloads, name)
+
+	for i := range f.variants {
+		if f.variants[i].Name == name {
+			f.variants = append(f.variants[:i:i], f.variants[i+1:]...)
+			return
+		}
+	}
+}
+
+// variant returns the variant assigned to the actor of the evaluation, empty when the feature isn't active
+// for it or has no weighted variants.
+//
+// Variants are assigned by rendezvous hashing: the actor is hashed along with the name of every variant,
+// the same way it's bucketed for percentage rollouts, and gets the variant with the highest weighted score.
+// Changing the weight of a variant only moves actors to or from that variant, never between other variants.
+func (f *Feature) variant(e evaluation) string {
+	if len(f.variants) == 0 || !f.evaluate(e) {
+		return ""
+	}
+
+	key := f.bucketKey(e.actor, e.randomizePercentage)
+
+	var assigned string
+	var highest float64
+	for _, v := range f.variants {
+		if v.Weight == 0 {
+			continue
+		}
+
+		// map the hash into (0, 1), so its logarithm is finite and negative
+		sum := f.hash.sum([]byte(string(key) + ":" + v.Name))
+		score := float64(v.Weight) / -math.Log((float64(sum)+0.5)/(math.MaxUint32+1))
+
+		if assigned == "" || score > highest {
+			assigned, highest = v.Name, score
+		}
+	}
+
+	return assigned
+}
+
+// SetVariant sets the weight of the variant of the feature, adding the variant when the feature doesn't have it.
+// Actors are only moved to or from the variant, the assignments of the other variants stay the same.
+func (m *Manager) SetVariant(feature *Feature, name string, weight uint32) error {
+	return m.SetVariantContext(context.Background(), feature, name, weight)
+}
+
+// SetVariantContext sets the weight of the variant of the feature, bounded by the context
+func (m *Manager) SetVariantContext(ctx context.Context, feature *Feature, name string, weight uint32) error {
+	if name == "" {
+		return errors.New("variant is missing the name")
+	}
+
+	return m.update(ctx, feature, operation("set-variant", name, weight), func(f *Feature) {
+		f.setVariant(name, weight)
+	})
+}
+
+// RemoveVariant removes the variant from the feature, reassigning its actors to the remaining variants
+func (m *Manager) RemoveVariant(feature *Feature, name string) error {
+	return m.RemoveVariantContext(context.Background(), feature, name)
+}
+
+// RemoveVariantContext removes the variant from the feature, bounded by the context
+func (m *Manager) RemoveVariantContext(ctx context.Context, feature *Feature, name string) error {
+	return m.update(ctx, feature, operation("remove-variant", name), func(f *Feature) {
+		f.removeVariant(name)
+	})
+}
+
+// TeamVariant returns the variant of the feature assigned to a team, empty when the feature
+// isn't active for the team or has no weighted variants
+func (m *Manager) TeamVariant(teamID int64, feature *Feature) (string, error) {
+	return m.TeamVariantContext(context.Background(), teamID, feature)
+}
+
+// TeamVariantContext returns the variant of the feature assigned to a team, bounded by the context
+func (m *Manager) TeamVariantContext(ctx context.Context, teamID int64, feature *Feature) (string, error) {
+	return m.ActorVariantWithAttributesContext(ctx, Team(teamID), nil, feature)
+}
+
+// ActorVariant returns the variant of the feature assigned to an actor, empty when the feature
+// isn't active for the actor or has no weighted variants
+func (m *Manager) ActorVariant(actor Actor, feature *Feature) (string, error) {
+	return m.ActorVariantContext(context.Background(), actor, feature)
+}
+
+// ActorVariantContext returns the variant of the feature assigned to an actor, bounded by the context
+func (m *Manager) ActorVariantContext(ctx context.Context, actor Actor, feature *Feature) (string, error) {
+	return m.ActorVariantWithAttributesContext(ctx, actor, nil, feature)
+}
+
+// ActorVariantWithAttributes returns the variant of the feature assigned to an actor with the attributes,
+// which are matched against the targeting rules of the feature
+func (m *Manager) ActorVariantWithAttributes(actor Actor, attributes Attributes, feature *Feature) (string, error) {
+	return m.ActorVariantWithAttributesContext(context.Background(), actor, attributes, feature)
+}
+
+// ActorVariantWithAttributesContext returns the variant of the feature assigned to an actor with the attributes, bounded by the context
+func (m *Manager) ActorVariantWithAttributesContext(ctx context.Context, actor Actor, attributes Attributes, feature *Feature) (string, error) {
+	state, err := m.load(ctx, feature)
+	if err != nil {
+		return "", err
+	}
+
+	segments, err := m.loadSegments(ctx, state)
+	if err != nil {
+		return "", err
+	}
+
+	e := m.evaluation(actor, attributes, segments)
+	if active, err := m.evaluate(ctx, state, e); err != nil || !active {
+		return "", err
+	}
+
+	return state.variant(e), nil
+}
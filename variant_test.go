@@ -0,0 +1,85 @@
+package rollout
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVariant(t *testing.T) {
+	f := NewFeature("example")
+	f.setVariant("control", 50)
+	f.setVariant("blue", 25)
+	f.setVariant("green", 25)
+
+	// no variant while the feature isn't active
+	assert.Empty(t, f.variant(evaluation{actor: Team(1)}))
+
+	f.activate()
+	counts := map[string]int{}
+	assigned := map[int64]string{}
+	for teamID := int64(0); teamID < 10000; teamID++ {
+		v := f.variant(evaluation{actor: Team(teamID), randomizePercentage: true})
+		counts[v]++
+		assigned[teamID] = v
+	}
+	assert.InDelta(t, 5000, counts["control"], 300)
+	assert.InDelta(t, 2500, counts["blue"], 300)
+	assert.InDelta(t, 2500, counts["green"], 300)
+
+	// assignments are sticky
+	assert.Equal(t, assigned[1], f.variant(evaluation{actor: Team(1), randomizePercentage: true}))
+
+	// changing a weight only moves teams to or from that variant
+	f.setVariant("green", 75)
+	var moved int
+	for teamID := int64(0); teamID < 10000; teamID++ {
+		v := f.variant(evaluation{actor: Team(teamID), randomizePercentage: true})
+		if v != assigned[teamID] {
+			assert.Equal(t, "green", v)
+			moved++
+		}
+	}
+	assert.NotZero(t, moved)
+
+	// removed and zero weighted variants aren't assigned
+	f.removeVariant("green")
+	f.setVariant("blue", 0)
+	for teamID := int64(0); teamID < 100; teamID++ {
+		assert.Equal(t, "control", f.variant(evaluation{actor: Team(teamID)}))
+	}
+	assert.Equal(t, []Variant{{Name: "control", Weight: 50}, {Name: "blue", Weight: 0}}, f.Variants())
+}
+
+func TestManagerVariant(t *testing.T) {
+	manager := NewManagerWithStore(NewMemoryStore(), mockKeyPrefix, true)
+
+	f := NewFeature("example")
+	assert.NoError(t, manager.SetVariant(f, "control", 1))
+	assert.NoError(t, manager.SetVariant(f, "treatment", 1))
+	assert.EqualError(t, manager.SetVariant(f, "", 1), "variant is missing the name")
+
+	variant, err := manager.TeamVariant(1, f)
+	assert.NoError(t, err)
+	assert.Empty(t, variant)
+
+	assert.NoError(t, manager.ActivateTeam(1, f))
+
+	variant, err = manager.TeamVariant(1, f)
+	assert.NoError(t, err)
+	assert.Contains(t, []string{"control", "treatment"}, variant)
+
+	assert.NoError(t, manager.RemoveVariant(f, variant))
+
+	other, err := manager.ActorVariant(Team(1), f)
+	assert.NoError(t, err)
+	assert.NotEqual(t, variant, other)
+
+	// targeting rules activate the feature, and so its variants
+	country, _ := ParseClause("country eq CA")
+	assert.NoError(t, manager.AddRule(f, Rule{country}))
+
+	variant, err = manager.ActorVariantWithAttributes(NewActor(KindUser, "abc"), Attributes{"country": "CA"}, f)
+	assert.NoError(t, err)
+	assert.Equal(t, other, variant)
+}